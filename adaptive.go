@@ -0,0 +1,93 @@
+package tcp
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// rttSamples is the number of recent RTTs kept per target.
+	rttSamples = 32
+	// rttMinSamples is the number of RTTs required before a timeout is adapted.
+	rttMinSamples = 8
+	// rttMaxTargets bounds the number of targets tracked, an arbitrary one is evicted beyond it.
+	rttMaxTargets = 4096
+)
+
+// rttRing is a ring buffer of recent RTTs.
+type rttRing struct {
+	samples [rttSamples]time.Duration
+	n       int
+	next    int
+}
+
+func (r *rttRing) add(rtt time.Duration) {
+	r.samples[r.next] = rtt
+	r.next = (r.next + 1) % rttSamples
+	if r.n < rttSamples {
+		r.n++
+	}
+}
+
+func (r *rttRing) p95() time.Duration {
+	sorted := make([]time.Duration, r.n)
+	copy(sorted, r.samples[:r.n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(r.n*95+99)/100-1]
+}
+
+// rttHistory keeps the recent RTTs of targets for adapting their timeouts.
+type rttHistory struct {
+	l       sync.Mutex
+	floor   time.Duration
+	k       float64
+	targets map[string]*rttRing
+}
+
+func newRTTHistory(floor time.Duration, k float64) *rttHistory {
+	return &rttHistory{floor: floor, k: k, targets: make(map[string]*rttRing)}
+}
+
+func (h *rttHistory) add(addr string, rtt time.Duration) {
+	h.l.Lock()
+	defer h.l.Unlock()
+	ring, exists := h.targets[addr]
+	if !exists {
+		if len(h.targets) >= rttMaxTargets {
+			for evicted := range h.targets {
+				delete(h.targets, evicted)
+				break
+			}
+		}
+		ring = &rttRing{}
+		h.targets[addr] = ring
+	}
+	ring.add(rtt)
+}
+
+// timeout returns max(floor, k * p95 RTT) of addr if it's less than timeout, otherwise timeout.
+func (h *rttHistory) timeout(addr string, timeout time.Duration) time.Duration {
+	h.l.Lock()
+	defer h.l.Unlock()
+	ring, exists := h.targets[addr]
+	if !exists || ring.n < rttMinSamples {
+		return timeout
+	}
+	adapted := time.Duration(h.k * float64(ring.p95()))
+	if adapted < h.floor {
+		adapted = h.floor
+	}
+	if adapted < timeout {
+		return adapted
+	}
+	return timeout
+}
+
+// adaptTimeout returns the timeout to be used for checking addr.
+func (c *Checker) adaptTimeout(addr string, timeout time.Duration) time.Duration {
+	if c.rtts == nil {
+		return timeout
+	}
+	return c.rtts.timeout(addr, timeout)
+}
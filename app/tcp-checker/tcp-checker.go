@@ -121,8 +121,9 @@ func (cc *ConcurrentChecker) Count(i int) uint64 {
 func (cc *ConcurrentChecker) Launch(ctx context.Context) error {
 	var err error
 	go func() {
-		err := cc.checker.CheckingLoop(ctx)
-		log.Fatal("Error during checking loop: ", err)
+		if err := cc.checker.CheckingLoop(ctx); err != context.Canceled {
+			log.Fatal("Error during checking loop: ", err)
+		}
 	}()
 
 	for i := 0; i < cc.conf.Concurrency; i++ {
@@ -0,0 +1,65 @@
+package tcp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CheckHandle is the handle of a check started by CheckAddrAsync.
+type CheckHandle struct {
+	ctx    *cancelContext
+	result chan CheckResult
+}
+
+// Cancel cancels the check if it's not finished, the socket is removed from the poller and closed,
+// and the check results in ErrCanceled. It's safe to call Cancel multiple times.
+func (h *CheckHandle) Cancel() {
+	h.ctx.cancel()
+}
+
+// Result returns a chan receiving the result of the check once it's finished.
+func (h *CheckHandle) Result() <-chan CheckResult {
+	return h.result
+}
+
+// CheckAddrAsync starts checking addr in a new goroutine and returns immediately,
+// the check can be canceled via the returned handle.
+func (c *Checker) CheckAddrAsync(addr string, timeout time.Duration) *CheckHandle {
+	h := &CheckHandle{
+		ctx:    newCancelContext(),
+		result: make(chan CheckResult, 1),
+	}
+	go func() {
+		h.result <- *c.checkAddr(h.ctx, addr, timeout, c.zeroLinger)
+	}()
+	return h
+}
+
+// cancelContext is a context whose error is ErrCanceled once canceled.
+type cancelContext struct {
+	context.Context
+	once sync.Once
+	done chan struct{}
+}
+
+func newCancelContext() *cancelContext {
+	return &cancelContext{Context: context.Background(), done: make(chan struct{})}
+}
+
+func (ctx *cancelContext) Done() <-chan struct{} {
+	return ctx.done
+}
+
+func (ctx *cancelContext) Err() error {
+	select {
+	case <-ctx.done:
+		return ErrCanceled
+	default:
+		return nil
+	}
+}
+
+func (ctx *cancelContext) cancel() {
+	ctx.once.Do(func() { close(ctx.done) })
+}
@@ -0,0 +1,45 @@
+package tcp
+
+import "bytes"
+
+// maxLineSize is the maximum length of a line read by CheckAddrLines.
+const maxLineSize = 4096
+
+// bannerSize returns the number of bytes to read for comparing a banner with prefix,
+// at least one byte is read so that an empty prefix still requires a banner.
+func bannerSize(prefix []byte) int {
+	if len(prefix) == 0 {
+		return 1
+	}
+	return len(prefix)
+}
+
+// matchLines reads via read and calls match with every line without the line ending until it's done,
+// lines split across reads are joined. ErrBannerMismatch is returned if match is done but not ok.
+func matchLines(read func(buf []byte) (int, error), match func(line []byte) (done bool, ok bool)) error {
+	buf := make([]byte, maxLineSize)
+	var n int
+	for {
+		for {
+			i := bytes.IndexByte(buf[:n], '\n')
+			if i < 0 {
+				break
+			}
+			if done, ok := match(bytes.TrimSuffix(buf[:i], []byte("\r"))); done {
+				if ok {
+					return nil
+				}
+				return ErrBannerMismatch
+			}
+			n = copy(buf, buf[i+1:n])
+		}
+		if n == len(buf) {
+			return ErrLineTooLong
+		}
+		nr, err := read(buf[n:])
+		n += nr
+		if err != nil && nr == 0 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CheckAddrBanner performs a TCP check and then waits for the server to send a banner
+// starting with prefix, an empty prefix accepts any banner.
+// The connect and read phases are limited by connectTimeout and readTimeout respectively,
+// ErrConnectTimeout or ErrReadTimeout is returned accordingly.
+// ErrBannerMismatch is returned if the banner doesn't start with prefix.
+func (c *Checker) CheckAddrBanner(addr string, connectTimeout, readTimeout time.Duration, prefix []byte) error {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return result.Err
+	}
+	defer c.inflight.Done()
+
+	ctx := context.Background()
+	var readPhase bool
+	result.Err = c.doCheckAddr(ctx, result, connectTimeout, c.zeroLinger, func(fd int) error {
+		readPhase = true
+		return c.readBanner(ctx, fd, time.Now().Add(readTimeout), prefix)
+	})
+	if errors.Is(result.Err, ErrTimeout) {
+		if readPhase {
+			result.Err = ErrReadTimeout
+		} else {
+			result.Err = ErrConnectTimeout
+		}
+	}
+	return result.Err
+}
+
+// CheckAddrLines performs a TCP check and then reads what the server sends line by line,
+// match is called with every line without the line ending until it's done, e.g. to find a capability
+// advertised by a multi-line SMTP greeting. The check succeeds if match is done and ok,
+// ErrBannerMismatch is returned if it's done but not ok. timeout covers the whole process.
+// NOTE: line is only valid until match returns.
+func (c *Checker) CheckAddrLines(addr string, timeout time.Duration, match func(line []byte) (done bool, ok bool)) error {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return result.Err
+	}
+	defer c.inflight.Done()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(timeout)
+	result.Err = c.doCheckAddr(ctx, result, timeout, c.zeroLinger, func(fd int) error {
+		return matchLines(func(buf []byte) (int, error) {
+			return c.readAtLeast(ctx, fd, buf, 1, deadline)
+		}, match)
+	})
+	return result.Err
+}
+
+// readBanner reads from fd until the banner is long enough to be compared with prefix.
+func (c *Checker) readBanner(ctx context.Context, fd int, deadline time.Time, prefix []byte) error {
+	banner := make([]byte, bannerSize(prefix))
+	n, err := c.readAtLeast(ctx, fd, banner, len(banner), deadline)
+	if len(prefix) > 0 && !bytes.HasPrefix(prefix, banner[:n]) {
+		return ErrBannerMismatch
+	}
+	return err
+}
+
+// readAtLeast reads from non-blocking fd into buf until at least min bytes are read, the buffer is full,
+// or deadline is reached. The number of bytes read is returned along with the reason of stopping early.
+func (c *Checker) readAtLeast(ctx context.Context, fd int, buf []byte, min int, deadline time.Time) (int, error) {
+	// The ACK of handshake is delayed since TCP_QUICKACK is disabled,
+	// send it now since the server may wait for it before sending anything.
+	unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_QUICKACK, 1)
+	if c.recvTimeout > 0 {
+		if recvDeadline := time.Now().Add(c.recvTimeout); recvDeadline.Before(deadline) {
+			deadline = recvDeadline
+		}
+	}
+	var n int
+	for n < min && n < len(buf) {
+		nr, err := unix.Read(fd, buf[n:])
+		switch {
+		case err == unix.EAGAIN || err == unix.EINTR:
+			// Wait for more data
+			if err = c.waitEvent(ctx, fd, c.ioWaitTimeout(deadline), rearmReadEvents); err != nil {
+				return n, err
+			}
+		case err != nil:
+			return n, os.NewSyscallError("read", err)
+		case nr == 0:
+			return n, io.EOF
+		default:
+			n += nr
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,83 @@
+// +build !linux
+
+package tcp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"time"
+)
+
+// CheckAddrBanner performs a TCP check and then waits for the server to send a banner
+// starting with prefix, an empty prefix accepts any banner.
+// The connect and read phases are limited by connectTimeout and readTimeout respectively,
+// ErrConnectTimeout or ErrReadTimeout is returned accordingly.
+// ErrBannerMismatch is returned if the banner doesn't start with prefix.
+func (c *Checker) CheckAddrBanner(addr string, connectTimeout, readTimeout time.Duration, prefix []byte) error {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	result.Err = c.checkBanner(result, connectTimeout, readTimeout, prefix)
+	return result.Err
+}
+
+func (c *Checker) checkBanner(result *CheckResult, connectTimeout, readTimeout time.Duration, prefix []byte) error {
+	startedAt := time.Now()
+	dialer := c.newDialer(connectTimeout)
+	conn, err := dialer.Dial("tcp", result.Addr)
+	result.Latency = time.Since(startedAt)
+	if err != nil {
+		if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+			return ErrConnectTimeout
+		}
+		return err
+	}
+	defer conn.Close()
+	result.LocalAddr = conn.LocalAddr()
+	if c.zeroLinger {
+		// Simply ignore the error since this is a fake implementation.
+		conn.(*net.TCPConn).SetLinger(0)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	banner := make([]byte, bannerSize(prefix))
+	n, err := io.ReadFull(conn, banner)
+	if len(prefix) > 0 && !bytes.HasPrefix(prefix, banner[:n]) {
+		return ErrBannerMismatch
+	}
+	if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+		return ErrReadTimeout
+	}
+	return err
+}
+
+// CheckAddrLines performs a TCP check and then reads what the server sends line by line,
+// match is called with every line without the line ending until it's done, e.g. to find a capability
+// advertised by a multi-line SMTP greeting. The check succeeds if match is done and ok,
+// ErrBannerMismatch is returned if it's done but not ok. timeout covers the whole process.
+// NOTE: line is only valid until match returns.
+func (c *Checker) CheckAddrLines(addr string, timeout time.Duration, match func(line []byte) (done bool, ok bool)) error {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	startedAt := time.Now()
+	dialer := c.newDialer(timeout)
+	conn, err := dialer.Dial("tcp", addr)
+	result.Latency = time.Since(startedAt)
+	if err != nil {
+		result.Err = normalizeDialError(err)
+		return result.Err
+	}
+	defer conn.Close()
+	result.LocalAddr = conn.LocalAddr()
+	if c.zeroLinger {
+		// Simply ignore the error since this is a fake implementation.
+		conn.(*net.TCPConn).SetLinger(0)
+	}
+
+	conn.SetReadDeadline(startedAt.Add(timeout))
+	result.Err = matchLines(func(buf []byte) (int, error) {
+		n, err := conn.Read(buf)
+		return n, normalizeDialError(err)
+	}, match)
+	return result.Err
+}
@@ -0,0 +1,151 @@
+package tcp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchMode controls when a batch of checks stops.
+type BatchMode int
+
+const (
+	// WaitAll waits for all checks of the batch to finish.
+	WaitAll BatchMode = iota
+	// StopOnFirstSuccess stops the batch as soon as one address is checked successfully.
+	StopOnFirstSuccess
+	// StopOnFirstFailure stops the batch as soon as one address fails the check.
+	StopOnFirstFailure
+)
+
+// CheckAddrs checks given addresses in parallel with the same timeout,
+// the results are keyed by address. Every address has a result,
+// the ones still pending when timeout is reached are reported with ErrTimeout.
+func (c *Checker) CheckAddrs(addrs []string, timeout time.Duration) map[string]error {
+	results, _ := c.CheckAddrsUntil(addrs, timeout, WaitAll)
+	return results
+}
+
+// CheckAddrsUntil is like CheckAddrs but stops the batch early according to mode,
+// in which case the remaining checks are canceled with context.Canceled as their results.
+// The address that triggered the stop is returned, or an empty string if the batch was not stopped.
+func (c *Checker) CheckAddrsUntil(addrs []string, timeout time.Duration, mode BatchMode) (map[string]error, string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pending := make(map[string]struct{}, len(addrs))
+	resultC := make(chan *CheckResult)
+	for _, addr := range addrs {
+		if _, exists := pending[addr]; exists {
+			continue
+		}
+		pending[addr] = struct{}{}
+		go func(addr string) {
+			resultC <- c.checkAddr(ctx, addr, timeout, c.zeroLinger)
+		}(addr)
+	}
+
+	var stoppedBy string
+	results := make(map[string]error, len(pending))
+	for range pending {
+		result := <-resultC
+		results[result.Addr] = result.Err
+		if stoppedBy == "" && shouldStop(mode, result) {
+			stoppedBy = result.Addr
+			cancel()
+		}
+	}
+	return results, stoppedBy
+}
+
+// CheckAddrsStream is like CheckAddrs but the results are sent in the order of completion,
+// so that the fast responders can be handled before the slow ones are finished.
+// Every address has a result, the chan is closed once all of them are sent,
+// which is no later than timeout. The chan is buffered so it's fine to stop receiving early.
+func (c *Checker) CheckAddrsStream(addrs []string, timeout time.Duration) <-chan CheckResult {
+	unique := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		unique[addr] = struct{}{}
+	}
+	resultC := make(chan CheckResult, len(unique))
+	var wg sync.WaitGroup
+	wg.Add(len(unique))
+	for addr := range unique {
+		go func(addr string) {
+			defer wg.Done()
+			resultC <- *c.checkAddr(context.Background(), addr, timeout, c.zeroLinger)
+		}(addr)
+	}
+	go func() {
+		wg.Wait()
+		close(resultC)
+	}()
+	return resultC
+}
+
+// CheckAny checks given addresses one by one in order until one of them passes the check,
+// which is returned. Unlike CheckAddrs, the addresses are not checked in parallel.
+// If all of them failed, *ErrAllFailed is returned with the error of each address.
+func (c *Checker) CheckAny(addrs []string, perAddrTimeout time.Duration) (string, error) {
+	allFailed := &ErrAllFailed{}
+	for _, addr := range addrs {
+		err := c.CheckAddr(addr, perAddrTimeout)
+		if err == nil {
+			return addr, nil
+		}
+		allFailed.add(addr, err)
+	}
+	return "", allFailed
+}
+
+// CheckAll checks given addresses in parallel like CheckAddrs and returns nil only if all of them passed,
+// otherwise *ErrSomeFailed is returned with the failed addresses in the given order and their errors.
+func (c *Checker) CheckAll(addrs []string, timeout time.Duration) error {
+	results := c.CheckAddrs(addrs, timeout)
+	someFailed := &ErrSomeFailed{}
+	for _, addr := range addrs {
+		if err := results[addr]; err != nil {
+			someFailed.add(addr, err)
+			// report duplicated addresses once
+			delete(results, addr)
+		}
+	}
+	if len(someFailed.Addrs) > 0 {
+		return someFailed
+	}
+	return nil
+}
+
+// PartitionHealthy checks given addresses in parallel like CheckAddrs and partitions them into healthy
+// and unhealthy ones, both in the given order, use CheckAddrs for the error of each address.
+// An error is returned only if the checks could not be performed at all, e.g. ErrNotReady.
+func (c *Checker) PartitionHealthy(addrs []string, timeout time.Duration) (healthy, unhealthy []string, err error) {
+	results := c.CheckAddrs(addrs, timeout)
+	for _, addr := range addrs {
+		addrErr, checked := results[addr]
+		if !checked {
+			// report duplicated addresses once
+			continue
+		}
+		delete(results, addr)
+		switch addrErr {
+		case nil:
+			healthy = append(healthy, addr)
+		case ErrNotReady, ErrClosed:
+			return nil, nil, addrErr
+		default:
+			unhealthy = append(unhealthy, addr)
+		}
+	}
+	return healthy, unhealthy, nil
+}
+
+func shouldStop(mode BatchMode, result *CheckResult) bool {
+	switch mode {
+	case StopOnFirstSuccess:
+		return result.OK()
+	case StopOnFirstFailure:
+		return !result.OK()
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package tcp
+
+// Caps reports the features supported by the running binary and host.
+type Caps struct {
+	// Epoll reports whether checks are performed via epoll, otherwise they're faked by a full handshake.
+	Epoll bool
+	// QuickAck reports whether TCP_QUICKACK is supported.
+	QuickAck bool
+	// UserTimeout reports whether TCP_USER_TIMEOUT is supported.
+	UserTimeout bool
+	// FastOpen reports whether TCP_FASTOPEN_CONNECT(Linux 4.11+) is supported.
+	// It is informational only, checks never send data on the SYN so Fast Open is not used by them.
+	FastOpen bool
+	// MPTCP reports whether Multipath TCP sockets can be created.
+	MPTCP bool
+	// Priority reports whether SetPriority is permitted with a priority above 6.
+	Priority bool
+	// CongestionControl reports whether SetCongestionControl is supported.
+	CongestionControl bool
+}
@@ -0,0 +1,57 @@
+package tcp
+
+import (
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// ipprotoMPTCP is IPPROTO_MPTCP which is missing in golang.org/x/sys/unix.
+const ipprotoMPTCP = 262
+
+var (
+	capsOnce sync.Once
+	caps     Caps
+)
+
+// Capabilities returns the features supported by the running binary and host,
+// the host is probed on the first call only.
+func Capabilities() Caps {
+	capsOnce.Do(func() {
+		caps = probeCapabilities()
+	})
+	return caps
+}
+
+func probeCapabilities() Caps {
+	var caps Caps
+	if fd, err := createPoller(); err == nil {
+		caps.Epoll = true
+		unix.Close(fd)
+	}
+	caps.QuickAck = probeSockOpt(func(fd int) error {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_QUICKACK, 0)
+	}) == nil
+	caps.UserTimeout = probeSockOpt(func(fd int) error {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, 0)
+	}) == nil
+	caps.FastOpen = probeSockOpt(func(fd int) error {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+	}) == nil
+	caps.Priority = probeSockOpt(func(fd int) error {
+		// priorities above 6 require CAP_NET_ADMIN
+		return _setPriority(fd, 0x10001)
+	}) == nil
+	caps.CongestionControl = probeSockOpt(func(fd int) error {
+		name, err := unix.GetsockoptString(fd, unix.IPPROTO_TCP, unix.TCP_CONGESTION)
+		if err != nil {
+			return err
+		}
+		return _setCongestionControl(fd, name)
+	}) == nil
+	if fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM, ipprotoMPTCP); err == nil {
+		caps.MPTCP = true
+		unix.Close(fd)
+	}
+	return caps
+}
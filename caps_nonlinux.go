@@ -0,0 +1,9 @@
+// +build !linux
+
+package tcp
+
+// Capabilities returns the features supported by the running binary and host,
+// none of the advanced features is supported on this platform.
+func Capabilities() Caps {
+	return Caps{}
+}
@@ -0,0 +1,86 @@
+package tcp
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// checkIDKey is the context key of the correlation ID of a check.
+type checkIDKey struct{}
+
+// CheckAddrWithID is like CheckAddr but the check carries id, which is set to the ID of the result
+// reported via WithOnResult and SetJSONSink, and included in the lines logged for the check.
+func (c *Checker) CheckAddrWithID(id string, addr string, timeout time.Duration) error {
+	ctx := context.WithValue(context.Background(), checkIDKey{}, id)
+	return c.checkAddr(ctx, addr, timeout, c.zeroLinger).Err
+}
+
+// checkID returns the correlation ID carried by ctx, empty if there is none.
+func checkID(ctx context.Context) string {
+	id, _ := ctx.Value(checkIDKey{}).(string)
+	return id
+}
+
+// recordResult is called with the result of every finished check.
+func (c *Checker) recordResult(result *CheckResult) {
+	result.Kind = c.Classify(result.Err)
+	if IsInternal(result.Err) {
+		if result.ID != "" {
+			c.logf("tcp-shaker: [%s] %s: %s", result.ID, result.Addr, result.Err)
+		} else {
+			c.logf("tcp-shaker: %s: %s", result.Addr, result.Err)
+		}
+	}
+	if c.rtts != nil && result.OK() {
+		c.rtts.add(result.Addr, result.Latency)
+	}
+	if c.results != nil {
+		c.results.put(*result)
+	}
+	if !c.sink.emit(result) {
+		c.stats.addDroppedLine()
+	}
+	if c.onResult != nil {
+		c.safeCall("OnResult", func() { c.onResult(*result) })
+	}
+}
+
+// contextTimeout returns the timeout of the checks limited by a context, which is the one set by
+// WithDefaultTimeout if any, the context is the only limit otherwise.
+func (c *Checker) contextTimeout() time.Duration {
+	if c.defaultTimeout > 0 {
+		return c.defaultTimeout
+	}
+	return math.MaxInt64
+}
+
+// delayConnect waits for the delay set by WithConnectDelay, ErrTimeout is returned right away
+// if the delay would exceed deadline, ErrClosed if the Checker is closed meanwhile.
+func (c *Checker) delayConnect(ctx context.Context, deadline time.Time) error {
+	if c.connectDelay <= 0 {
+		return nil
+	}
+	if time.Now().Add(c.connectDelay).After(deadline) {
+		return ErrTimeout
+	}
+	timer := time.NewTimer(c.connectDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closing:
+		return ErrClosed
+	}
+}
+
+// LastResult returns the most recent result of given address,
+// false is returned if the address was not checked or WithResultCache is not set.
+func (c *Checker) LastResult(addr string) (CheckResult, bool) {
+	if c.results == nil {
+		return CheckResult{}, false
+	}
+	return c.results.get(addr)
+}
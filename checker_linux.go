@@ -2,54 +2,184 @@ package tcp
 
 import (
 	"context"
+	"os"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 )
 
 // Checker contains an epoll instance for TCP handshake checking.
+// All the checking methods are safe for concurrent use by multiple goroutines,
+// the setters not taking effect on running checks must be called before CheckingLoop.
 // NOTE: Ideally only one instance of Checker should be created within a process.
 type Checker struct {
+	config
 	pipePool
 	resultPipes
-	pollerLock sync.Mutex
-	_pollerFd  int32
-	zeroLinger bool
-	isReady    chan struct{}
+	fds               *fdLimiter
+	results           *resultCache
+	recent            *recentChecks
+	rtts              *rttHistory
+	sources           *sourcePool
+	pollerLock        sync.Mutex
+	_pollerFd         int32
+	zeroLinger        bool
+	priority          uint32
+	congestionControl string
+	mark              uint32
+	device            string
+	flowInfo          uint32
+	noDelay           bool
+	reuseAddr         bool
+	reusePort         bool
+	netns             *os.File
+	sockets           socketPool
+	warned            sync.Map
+	warmedHosts       sync.Map
+	socketWarmed      int32
+	eventHook         func(fd int, events uint32, err error)
+	eventBatchLimit   int32
+	errorClassifier   func(errno int) error
+	beforeConnectHook func(fd int)
+	afterConnectHook  func(fd int, success bool, err error)
+	stats             stats
+	sink              jsonSink
+	readyLock         sync.Mutex
+	isReady           chan struct{}
+	onReadyChange     func(ready bool)
+	readyNotifyLock   sync.Mutex
+	readyNotified     bool
+	closeLock         sync.RWMutex
+	closing           chan struct{}
+	closed            int32
+	starting          int32
+	inflight          sync.WaitGroup
 }
 
-// NewChecker creates a Checker with linger set to zero.
-func NewChecker() *Checker {
-	return NewCheckerZeroLinger(true)
+// NewChecker creates a Checker with linger set to zero, so that the sockets of checks are reset on close,
+// they never enter TIME_WAIT and their local ports are reusable right away.
+func NewChecker(opts ...Option) *Checker {
+	return NewCheckerZeroLinger(true, opts...)
 }
 
 // NewCheckerZeroLinger creates a Checker with zeroLinger set to given value.
-func NewCheckerZeroLinger(zeroLinger bool) *Checker {
-	return &Checker{
+func NewCheckerZeroLinger(zeroLinger bool, opts ...Option) *Checker {
+	conf := newConfig(opts)
+	return newChecker(conf, zeroLinger && !conf.gracefulClose)
+}
+
+func newChecker(conf config, zeroLinger bool) *Checker {
+	c := &Checker{
+		config:      conf,
+		fds:         newFDLimiter(conf.maxOpenFiles),
 		pipePool:    newPipePoolSyncPool(),
-		resultPipes: newResultPipesSyncMap(),
+		resultPipes: newResultPipesMU(),
 		_pollerFd:   -1,
 		zeroLinger:  zeroLinger,
 		isReady:     make(chan struct{}),
+		closing:     make(chan struct{}),
+	}
+	if conf.maxTargets > 0 {
+		c.results = newResultCache(conf.maxTargets)
+		if conf.minInterval > 0 {
+			c.recent = newRecentChecks(conf.minInterval, conf.maxTargets)
+		}
+	}
+	if conf.adaptiveK > 0 {
+		c.rtts = newRTTHistory(conf.adaptiveFloor, conf.adaptiveK)
+	}
+	if conf.routingMark != nil {
+		c.mark = *conf.routingMark
+		c.checkRoutingMark(c.mark)
 	}
+	if conf.vrf != "" {
+		c.device = conf.vrf
+		c.checkVRF(c.device)
+	}
+	if conf.sourceCIDR != "" {
+		c.setSourceCIDR(conf.sourceCIDR)
+	}
+	if !zeroLinger {
+		c.warnTimeWait()
+	}
+	return c
+}
+
+// Clone creates a Checker with the same options and settings, e.g. the ones set by SetMark or SetConnectHooks,
+// but nothing else is shared: it has its own poller once its CheckingLoop is started, zeroed stats,
+// an empty result cache and no JSON sink, so it can be derived from a template Checker and then tuned.
+func (c *Checker) Clone() *Checker {
+	clone := newChecker(c.config, c.zeroLinger)
+	clone.priority = c.priority
+	clone.congestionControl = c.congestionControl
+	clone.mark = c.mark
+	clone.device = c.device
+	clone.flowInfo = c.flowInfo
+	clone.noDelay = c.noDelay
+	clone.reuseAddr = c.reuseAddr
+	clone.reusePort = c.reusePort
+	clone.eventHook = c.eventHook
+	clone.eventBatchLimit = atomic.LoadInt32(&c.eventBatchLimit)
+	clone.errorClassifier = c.errorClassifier
+	clone.beforeConnectHook = c.beforeConnectHook
+	clone.afterConnectHook = c.afterConnectHook
+	if c.netns != nil {
+		// the file is closed when SetNetns is called again, so each Checker needs its own
+		if fd, err := unix.Dup(int(c.netns.Fd())); err == nil {
+			unix.CloseOnExec(fd)
+			clone.netns = os.NewFile(uintptr(fd), c.netns.Name())
+		} else {
+			clone.warnOnce("setns", &ErrNetns{Path: c.netns.Name(), Err: os.NewSyscallError("dup", err)})
+		}
+	}
+	return clone
 }
 
 // CheckingLoop must be called before anything else.
-// NOTE: this function blocks until ctx got canceled.
+// NOTE: this function blocks until ctx got canceled or the Checker is closed.
+// The reason of stopping is returned: ctx.Err() if ctx is done, ErrClosed if the Checker
+// is closed, ErrCheckerAlreadyStarted if the loop is already running, *ErrPoller otherwise.
+// Once it returned, the poller is closed and all pending checks are resolved with the same reason,
+// except that ErrPollerClosed is used for a failed poller.
+// Canceling ctx is the recommended way to stop the loop, which ties it to the lifetime of the application,
+// while Close is only necessary if the Checker is to be discarded.
 func (c *Checker) CheckingLoop(ctx context.Context) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	if c.lockPollThread {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+	atomic.StoreInt32(&c.starting, 1)
 	pollerFd, err := c.createPoller()
+	atomic.StoreInt32(&c.starting, 0)
+	if err == ErrCheckerAlreadyStarted {
+		return err
+	}
 	if err != nil {
-		return errors.Wrap(err, "error creating poller")
+		return &ErrPoller{Op: "error creating poller", Err: err}
 	}
+	// notify after the poller is closed, which is deferred below
+	defer c.notifyReadyChange()
 	defer c.closePoller()
 
+	c.stats.tick()
 	c.setReady()
+	c.notifyReadyChange()
 	defer c.resetReady()
 
-	return c.pollingLoop(ctx, pollerFd)
+	err = c.pollingLoop(ctx, pollerFd)
+	// checks still waiting can never be resolved once the loop stopped
+	if _, failed := err.(*ErrPoller); failed {
+		c.resultPipes.releaseResultPipes(ErrPollerClosed)
+	} else {
+		c.resultPipes.releaseResultPipes(err)
+	}
+	return err
 }
 
 func (c *Checker) createPoller() (int, error) {
@@ -82,42 +212,151 @@ func (c *Checker) closePoller() error {
 }
 
 func (c *Checker) setReady() {
+	c.readyLock.Lock()
 	close(c.isReady)
+	c.readyLock.Unlock()
 }
 
 func (c *Checker) resetReady() {
+	c.readyLock.Lock()
 	c.isReady = make(chan struct{})
+	c.readyLock.Unlock()
 }
 
 const pollerTimeout = time.Second
 
+// synProgressInterval is how often the progress of a handshake is inspected with WithSynProgressCheck.
+const synProgressInterval = 100 * time.Millisecond
+
+// synStuckRetransmits is the number of SYN retransmissions after which a handshake is considered stuck.
+const synStuckRetransmits = 2
+
+// tcpSynSent is TCP_SYN_SENT from include/net/tcp_states.h.
+const tcpSynSent = 2
+
+// singleSynTimeout is the timeout of handshakes with WithSingleSyn,
+// which is shortly before the initial RTO(1s) at which the SYN is retransmitted.
+const singleSynTimeout = 900 * time.Millisecond
+
 func (c *Checker) pollingLoop(ctx context.Context, pollerFd int) error {
+	var epollEvents []unix.EpollEvent
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return ctx.Err()
+		case <-c.closing:
+			return ErrClosed
 		default:
-			evts, err := pollEvents(pollerFd, pollerTimeout)
+			if batchLimit := c.batchLimit(); batchLimit != len(epollEvents) {
+				// the limit was changed while running
+				epollEvents = make([]unix.EpollEvent, batchLimit)
+			}
+			evts, err := pollEvents(pollerFd, epollEvents, pollerTimeout, &c.stats)
 			if err != nil {
+				if c.isShuttingDown(ctx) && isPollerClosedError(err) {
+					// the poller was closed by the shutdown
+					return c.stopReason(ctx)
+				}
 				// fatal error
-				return errors.Wrap(err, "error during polling loop")
+				return &ErrPoller{Op: "error during polling loop", Err: err}
 			}
 
 			c.handlePollerEvents(evts)
+			if len(evts) == len(epollEvents) {
+				// More events may be ready, yield before polling again so that
+				// goroutines registering or canceling checks are not starved.
+				runtime.Gosched()
+			}
 		}
 	}
 }
 
+// isShuttingDown reports whether the loop is about to stop.
+func (c *Checker) isShuttingDown(ctx context.Context) bool {
+	return ctx.Err() != nil || c.isClosed()
+}
+
+// stopReason returns the reason of the loop being shut down.
+func (c *Checker) stopReason(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrClosed
+}
+
 func (c *Checker) handlePollerEvents(evts []event) {
 	for _, e := range evts {
+		if c.eventHook != nil {
+			c.safeCall("event hook", func() { c.eventHook(e.Fd, e.Events, e.Err) })
+		}
 		if pipe, exists := c.resultPipes.popResultPipe(e.Fd); exists {
-			pipe <- e.Err
+			pipe <- c.classifyConnectError(e.Err)
 		}
 		// error pipe not found
 		// in this case, e.Fd should have been handled in the previous event.
 	}
 }
 
+// SetErrorClassifier sets a function consulted before the default mapping of the error number of a failed connect
+// to *ErrConnect, the error it returns is returned by the check instead unless it's nil, in which case the default applies.
+// It's useful for errnos with unusual semantics(e.g. introduced by custom kernel modules),
+// see WithClassifier for changing only the FailureKind.
+// NOTE: This must be called before checking. classify is mostly called by the checking loop, it should return quickly.
+func (c *Checker) SetErrorClassifier(classify func(errno int) error) {
+	c.errorClassifier = classify
+}
+
+// classifyConnectError maps err with the function set by SetErrorClassifier if it's a *ErrConnect.
+func (c *Checker) classifyConnectError(err error) error {
+	if c.errorClassifier == nil {
+		return err
+	}
+	if connErr, ok := err.(*ErrConnect); ok {
+		if errno, ok := connErr.error.(unix.Errno); ok {
+			var classified error
+			c.safeCall("error classifier", func() { classified = c.errorClassifier(int(errno)) })
+			if classified != nil {
+				return classified
+			}
+		}
+	}
+	return err
+}
+
+// SetEventBatchLimit sets the maximum number of events processed per polling, 32 by default.
+// The loop yields to other goroutines after processing a full batch, a smaller limit
+// keeps the latency of registering new checks low when lots of events are ready.
+// It may be called while the loop is running, which takes effect from the next polling
+// without affecting the checks in flight.
+func (c *Checker) SetEventBatchLimit(n int) {
+	atomic.StoreInt32(&c.eventBatchLimit, int32(n))
+}
+
+// batchLimit returns the limit set by SetEventBatchLimit, or the default one.
+func (c *Checker) batchLimit() int {
+	if n := atomic.LoadInt32(&c.eventBatchLimit); n > 0 {
+		return int(n)
+	}
+	return maxEpollEvents
+}
+
+// SetConnectHooks sets functions to be called right before and after the connect syscall
+// of every check, which is useful for precise instrumentation and injecting faults in tests.
+// success and err of after are what connect returned, success is false if the connect is in progress.
+// Either of them may be nil.
+// NOTE: This must be called before checking, the hooks block the check.
+func (c *Checker) SetConnectHooks(before func(fd int), after func(fd int, success bool, err error)) {
+	c.beforeConnectHook = before
+	c.afterConnectHook = after
+}
+
+// SetEventHook sets a function to be called with the raw epoll event mask
+// and the classified error of every event processed by the polling loop.
+// NOTE: This must be called before CheckingLoop, the hook blocks the loop.
+func (c *Checker) SetEventHook(hook func(fd int, events uint32, err error)) {
+	c.eventHook = hook
+}
+
 func (c *Checker) pollerFD() int {
 	return int(atomic.LoadInt32(&c._pollerFd))
 }
@@ -128,7 +367,10 @@ func (c *Checker) setPollerFD(fd int) {
 
 // CheckAddr performs a TCP check with given TCP address and timeout
 // A successful check will result in nil error
-// ErrTimeout is returned if timeout
+// ErrTimeout is returned if timeout, a negative timeout fails immediately without connecting
+// Whether a timeout while connecting is due to no response(ErrNoResponse) or the kernel giving up
+// retransmitting(ErrNetworkTimeout) is reported by CheckResult.TimeoutReason, see CheckAddrResult
+// ErrNotReady is returned if CheckingLoop is not running
 // zeroLinger is an optional parameter indicating if linger should be set to zero
 // for this particular connection
 // Note: timeout includes domain resolving
@@ -138,64 +380,394 @@ func (c *Checker) CheckAddr(addr string, timeout time.Duration) (err error) {
 
 // CheckAddrZeroLinger is like CheckAddr with an extra parameter indicating whether to enable zero linger.
 func (c *Checker) CheckAddrZeroLinger(addr string, timeout time.Duration, zeroLinger bool) error {
+	if !zeroLinger {
+		c.warnTimeWait()
+	}
+	return c.checkAddr(context.Background(), addr, timeout, zeroLinger).Err
+}
+
+// CheckAddrResult is like CheckAddr but returns a CheckResult with the details of the check.
+func (c *Checker) CheckAddrResult(addr string, timeout time.Duration) *CheckResult {
+	return c.checkAddr(context.Background(), addr, timeout, c.zeroLinger)
+}
+
+// CheckAddrContext is like CheckAddr but the check is limited by ctx instead of a timeout,
+// ctx.Err() is returned if ctx is done before the check is finished.
+// If a default timeout is set by WithDefaultTimeout, the sooner of it and the deadline of ctx applies:
+// ErrTimeout(or one of its subcategories) is returned if the timeout fires first, ctx.Err() otherwise.
+func (c *Checker) CheckAddrContext(ctx context.Context, addr string) error {
+	return c.checkAddr(ctx, addr, c.contextTimeout(), c.zeroLinger).Err
+}
+
+// connectAddr performs a check of addr, which is spaced by checkAddr if WithMinInterval is set.
+func (c *Checker) connectAddr(ctx context.Context, addr string, timeout time.Duration, zeroLinger bool) *CheckResult {
+	result := newCheckResult(addr)
+	result.ID = checkID(ctx)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return result
+	}
+	defer c.inflight.Done()
+	result.Timeout = c.adaptTimeout(addr, timeout)
+	var afterConnect func(fd int) error
+	if c.postConnectGrace > 0 {
+		afterConnect = func(fd int) error { return c.waitPostConnectGrace(ctx, fd) }
+	}
+	result.Err = c.doCheckAddr(ctx, result, result.Timeout, zeroLinger, afterConnect)
+	return result
+}
+
+// waitPostConnectGrace watches the connected fd for the grace period set by WithPostConnectGrace,
+// an error is returned if the connection is reset or closed within it.
+func (c *Checker) waitPostConnectGrace(ctx context.Context, fd int) error {
+	// The ACK of handshake is delayed since TCP_QUICKACK is disabled,
+	// the server is unable to accept the connection until it's sent.
+	unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_QUICKACK, 1)
+	err := c.waitEvent(ctx, fd, c.postConnectGrace, rearmHangupEvents)
+	switch err {
+	case ErrTimeout:
+		// nothing happened within the grace period
+		return nil
+	case nil:
+		return ErrClosedAfterConnect
+	}
+	return err
+}
+
+// doCheckAddr performs the check described by result and fills in the details along the way.
+// afterConnect is called with the connected fd if it's not nil, its error becomes the result of the check.
+func (c *Checker) doCheckAddr(ctx context.Context, result *CheckResult, timeout time.Duration, zeroLinger bool, afterConnect func(fd int) error) error {
+	if timeout < 0 {
+		// the deadline has already passed
+		return ErrTimeout
+	}
 	// Set deadline
 	deadline := time.Now().Add(timeout)
 
 	// Parse address
-	rAddr, family, err := parseSockAddr(addr)
+	rAddr, family, err := parseSockAddr(result.Addr, c.network, c.ipv4Mapped)
 	if err != nil {
 		return err
 	}
+	return c.doCheckSockAddr(ctx, result, rAddr, family, deadline, zeroLinger, afterConnect)
+}
+
+// doCheckSockAddr connects to the resolved rAddr and waits for the result until deadline.
+func (c *Checker) doCheckSockAddr(ctx context.Context, result *CheckResult, rAddr unix.Sockaddr, family int, deadline time.Time, zeroLinger bool, afterConnect func(fd int) error) error {
+	if tAddr := sockAddrToTCPAddr(rAddr); tAddr != nil {
+		result.RemoteAddr = tAddr
+	}
+	result.Network = familyNetwork(family)
+	// Wait for a free slot if the number of sockets is limited
+	if err := c.fds.acquire(ctx, deadline); err != nil {
+		return err
+	}
+	defer c.fds.release()
 	// Create socket with options set
-	fd, err := createSocketZeroLinger(family, zeroLinger)
+	fd, err := c.openSocket(family, zeroLinger)
 	if err != nil {
 		return err
 	}
 	// Socket should be closed anyway
 	defer unix.Close(fd)
+	releaseSource, err := c.bindSource(fd, family, rAddr)
+	if err != nil {
+		return err
+	}
+	defer releaseSource()
+	if err = c.delayConnect(ctx, deadline); err != nil {
+		return err
+	}
 
 	// Connect to the address
-	if success, cErr := connect(fd, rAddr); cErr != nil {
+	if c.beforeConnectHook != nil {
+		c.safeCall("before connect hook", func() { c.beforeConnectHook(fd) })
+	}
+	startedAt := time.Now()
+	success, cErr := c.connectSockAddr(fd, rAddr)
+	if c.onConnectStart != nil {
+		// called after connect so that the hook is not included in the latency
+		c.safeCall("OnConnectStart", func() { c.onConnectStart(result.Addr, startedAt) })
+	}
+	if c.afterConnectHook != nil {
+		c.safeCall("after connect hook", func() { c.afterConnectHook(fd, success, cErr) })
+	}
+	if cErr != nil {
 		// If there was an error, return it.
-		return &ErrConnect{cErr}
-	} else if success {
+		result.Latency = time.Since(startedAt)
+		return c.classifyConnectError(&ErrConnect{cErr})
+	}
+	result.LocalAddr = localAddr(fd)
+	if success {
 		// If the connect was successful, we are done.
-		return nil
+		result.Synchronous = true
+	} else {
+		// Otherwise wait for the result of connect.
+		connectDeadline := deadline
+		if c.singleSyn && startedAt.Add(singleSynTimeout).Before(deadline) {
+			// give up before the SYN is retransmitted
+			connectDeadline = startedAt.Add(singleSynTimeout)
+		}
+		if c.synProgress {
+			err = c.waitConnectProgress(ctx, fd, connectDeadline)
+		} else {
+			err = c.waitConnectResult(ctx, fd, connectDeadline.Sub(time.Now()))
+		}
 	}
-	// Otherwise wait for the result of connect.
-	return c.waitConnectResult(fd, deadline.Sub(time.Now()))
+	if err == nil && c.strictHandshake {
+		err = c.waitHandshakeConfirmed(ctx, fd, deadline)
+	}
+	if err == ErrTimeout || err == ErrNoResponse {
+		// ErrTimeout itself is returned for the callers comparing with it, the subcategory is in the result
+		result.TimeoutReason = connectTimeoutError(fd)
+		err = ErrTimeout
+	}
+	result.Latency = time.Since(startedAt)
+	if err != nil || afterConnect == nil {
+		return err
+	}
+	return afterConnect(fd)
 }
 
-func (c *Checker) waitConnectResult(fd int, timeout time.Duration) error {
+// CheckLoopback performs a TCP check against the listener of given fd,
+// which is useful for a service to verify that its own listener is accepting.
+// An unspecified listening address(e.g. 0.0.0.0) is checked via loopback.
+// NOTE: listenerFd is only inspected, it is never closed.
+func (c *Checker) CheckLoopback(listenerFd int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	rAddr, family, err := listenerSockAddr(listenerFd)
+	if err != nil {
+		return err
+	}
+	result := newCheckResult(sockAddrToTCPAddr(rAddr).String())
+	defer c.recordResult(result)
+	if err := c.beginCheck(); err != nil {
+		return err
+	}
+	defer c.inflight.Done()
+	return c.doCheckSockAddr(context.Background(), result, rAddr, family, deadline, c.zeroLinger, nil)
+}
+
+// CheckFD waits for the connect of given fd to finish, fd must be a TCP socket
+// which is already connected or connecting, e.g. handed over from another library.
+// NOTE: fd is owned by the caller, it's removed from the poller once the check is finished but never closed.
+func (c *Checker) CheckFD(fd int, timeout time.Duration) error {
+	if err := c.beginCheck(); err != nil {
+		return err
+	}
+	defer c.inflight.Done()
+	err := c.waitConnectResult(context.Background(), fd, timeout)
+	if pollerFd := c.pollerFD(); pollerFd > 0 {
+		// fd remains open, it must not be reported to the poller anymore
+		unregisterEvents(pollerFd, fd)
+	}
+	if err != nil {
+		return err
+	}
+	// a socket never connected is reported writable as well
+	if _, err = unix.Getpeername(fd); err != nil {
+		return &ErrConnect{err}
+	}
+	return nil
+}
+
+func (c *Checker) waitConnectResult(ctx context.Context, fd int, timeout time.Duration) error {
+	return c.waitEvent(ctx, fd, timeout, registerEvents)
+}
+
+// waitEvent waits for the next poller event of fd, register is used to add fd to the poller.
+func (c *Checker) waitEvent(ctx context.Context, fd int, timeout time.Duration, register func(pollerFd int, fd int) error) error {
 	// get a pipe of connect result
 	resultPipe := c.getPipe()
+	// this must be done before registerEvents
+	if !c.resultPipes.registerResultPipe(fd, resultPipe) {
+		// the pipe of another check must not be replaced
+		c.putBackPipe(resultPipe)
+		return errFdRegistered
+	}
+	var resolved bool
 	defer func() {
-		c.resultPipes.deregisterResultPipe(fd)
+		if !c.resultPipes.deregisterResultPipe(fd) && !resolved {
+			// The pipe was popped but the result is not received yet,
+			// wait for it so that it never reaches the next user of the pipe.
+			<-resultPipe
+		}
 		c.putBackPipe(resultPipe)
 	}()
 
-	// this must be done before registerEvents
-	c.resultPipes.registerResultPipe(fd, resultPipe)
 	// Register to epoll for later error checking
-	if err := registerEvents(c.pollerFD(), fd); err != nil {
+	pollerFd := c.pollerFD()
+	if pollerFd <= 0 {
+		// the checking loop stopped during the check
+		return ErrPollerClosed
+	}
+	if err := register(pollerFd, fd); err != nil {
+		if c.isClosed() {
+			// the poller may have been closed along with the Checker
+			return ErrClosed
+		}
 		return err
 	}
 
 	// Wait for connect result
-	return c.waitPipeTimeout(resultPipe, timeout)
+	var err error
+	resolved, err = c.waitPipeTimeout(ctx, resultPipe, timeout)
+	if !resolved {
+		// fd is still pending, remove it from the poller explicitly
+		unregisterEvents(pollerFd, fd)
+	}
+	return err
 }
 
-func (c *Checker) waitPipeTimeout(pipe chan error, timeout time.Duration) error {
+// waitPipeTimeout waits for the result from pipe, resolved is false if it's not received.
+func (c *Checker) waitPipeTimeout(ctx context.Context, pipe chan error, timeout time.Duration) (resolved bool, err error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 	select {
 	case ret := <-pipe:
-		return ret
-	case <-time.After(timeout):
-		return ErrTimeout
+		return true, ret
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+		if err := ctx.Err(); err != nil {
+			// the deadline of ctx was reached at the same time
+			return false, err
+		}
+		return false, ErrTimeout
+	case <-c.closing:
+		return false, ErrClosed
+	}
+}
+
+// waitConnectProgress is waitConnectResult inspecting the progress of the handshake periodically,
+// ErrNoResponse is returned once it's stuck, see WithSynProgressCheck.
+func (c *Checker) waitConnectProgress(ctx context.Context, fd int, deadline time.Time) error {
+	for {
+		timeout := time.Until(deadline)
+		if timeout > synProgressInterval {
+			timeout = synProgressInterval
+		}
+		err := c.waitConnectResult(ctx, fd, timeout)
+		if err != ErrTimeout || !time.Now().Before(deadline) {
+			return err
+		}
+		info, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+		if err == nil && info.State == tcpSynSent && info.Retransmits >= synStuckRetransmits {
+			return ErrNoResponse
+		}
+	}
+}
+
+// waitHandshakeConfirmed confirms that the handshake of the writable fd is complete for WithStrictHandshake,
+// the next write event is waited for until deadline if the peer is not known yet.
+func (c *Checker) waitHandshakeConfirmed(ctx context.Context, fd int, deadline time.Time) error {
+	for {
+		done, err := handshakeConfirmed(fd)
+		if err != nil || done {
+			return err
+		}
+		if err = c.waitConnectResult(ctx, fd, time.Until(deadline)); err != nil {
+			return err
+		}
+	}
+}
+
+// handshakeConfirmed reports whether the handshake of fd is complete, i.e. SO_ERROR is 0 and getpeername succeeds,
+// an error is returned if the connect failed.
+func handshakeConfirmed(fd int) (bool, error) {
+	errCode, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
+	if err != nil {
+		return false, &ErrInternal{Op: "getsockopt SO_ERROR", Err: err}
+	}
+	if errCode != 0 {
+		return false, newErrConnect(errCode)
+	}
+	switch _, err = unix.Getpeername(fd); err {
+	case nil:
+		return true, nil
+	case unix.ENOTCONN:
+		return false, nil
+	}
+	return false, &ErrInternal{Op: "getpeername", Err: err}
+}
+
+// connectTimeoutError returns the subcategory of ErrTimeout for fd which is still connecting.
+func connectTimeoutError(fd int) error {
+	errCode, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
+	if err == nil && unix.Errno(errCode) == unix.ETIMEDOUT {
+		return ErrNetworkTimeout
+	}
+	return ErrNoResponse
+}
+
+// beginCheck registers an in-flight check.
+// ErrClosed is returned if the Checker is closed, ErrNotReady if CheckingLoop is not running.
+func (c *Checker) beginCheck() error {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+	if c.isClosed() {
+		return ErrClosed
+	}
+	// The poller is usable as soon as it's created,
+	// so a check issued while the loop is starting up can proceed safely.
+	if !c.IsReady() {
+		return ErrNotReady
+	}
+	c.inflight.Add(1)
+	return nil
+}
+
+func (c *Checker) isClosed() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the checking loop, which releases the poller.
+// In-flight checks return ErrClosed, so do checks issued after Close.
+// NOTE: Close blocks until all in-flight checks returned.
+func (c *Checker) Close() error {
+	c.closeLock.Lock()
+	if !c.isClosed() {
+		close(c.closing)
+	}
+	c.closeLock.Unlock()
+	c.notifyReadyChange()
+
+	c.inflight.Wait()
+	c.sink.setWriter(nil)
+	c.sockets.closeAll()
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+// IsClosed reports whether Close was called, checks issued afterwards return ErrClosed.
+func (c *Checker) IsClosed() bool {
+	return c.isClosed()
+}
+
+// State returns the lifecycle state of the Checker.
+func (c *Checker) State() State {
+	switch {
+	case atomic.LoadInt32(&c.closed) == 1:
+		return StateClosed
+	case c.isClosed():
+		return StateShuttingDown
+	case c.IsReady():
+		return StateRunning
+	case atomic.LoadInt32(&c.starting) == 1:
+		return StateStarting
 	}
+	return StateNew
 }
 
 // WaitReady returns a chan which is closed when the Checker is ready for use.
 func (c *Checker) WaitReady() <-chan struct{} {
+	c.readyLock.Lock()
+	defer c.readyLock.Unlock()
 	return c.isReady
 }
 
@@ -204,6 +776,67 @@ func (c *Checker) IsReady() bool {
 	return c.pollerFD() > 0
 }
 
+// OnReadyChange sets a function to be called when the Checker becomes ready, i.e. CheckingLoop is running,
+// and when it's no longer ready since the loop stopped or Close was called.
+// f is called in a separate goroutine so that it may call the Checker, the calls are serialized
+// and consecutive changes may be coalesced, but the latest state is always reported.
+// NOTE: This must be called before CheckingLoop.
+func (c *Checker) OnReadyChange(f func(ready bool)) {
+	c.onReadyChange = f
+}
+
+// notifyReadyChange calls the function set by OnReadyChange if the readiness changed since the last call.
+func (c *Checker) notifyReadyChange() {
+	if c.onReadyChange == nil {
+		return
+	}
+	go func() {
+		c.readyNotifyLock.Lock()
+		defer c.readyNotifyLock.Unlock()
+		ready := c.State() == StateRunning
+		if ready != c.readyNotified {
+			c.readyNotified = ready
+			c.safeCall("OnReadyChange", func() { c.onReadyChange(ready) })
+		}
+	}()
+}
+
+// LastLoopTick returns the last time the checking loop returned from polling, which happens
+// at least once per second while it's alive. Zero is returned if CheckingLoop was never started.
+func (c *Checker) LastLoopTick() time.Time {
+	return c.stats.lastTickTime()
+}
+
+// Healthy reports whether the checking loop is running and not wedged,
+// i.e. it returned from polling within the last 2 seconds.
+func (c *Checker) Healthy() bool {
+	return c.IsReady() && time.Since(c.LastLoopTick()) < 2*pollerTimeout
+}
+
+// warmupSocket creates a socket with all options applied and closes it, only once.
+// It's counted as a check in flight, so that it never races Close.
+func (c *Checker) warmupSocket() error {
+	if err := c.beginCheck(); err != nil {
+		return err
+	}
+	defer c.inflight.Done()
+	if atomic.LoadInt32(&c.socketWarmed) == 1 {
+		return nil
+	}
+	fd, err := c.newSocket(unix.AF_INET, c.zeroLinger)
+	if err != nil {
+		return err
+	}
+	unix.Close(fd)
+	atomic.StoreInt32(&c.socketWarmed, 1)
+	return nil
+}
+
+// OpenFiles returns the number of sockets currently opened by the Checker.
+func (c *Checker) OpenFiles() int {
+	return c.fds.count()
+}
+
 // PollerFd returns the inner fd of poller instance.
 // NOTE: Use this only when you really know what you are doing.
 func (c *Checker) PollerFd() int {
@@ -0,0 +1,722 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jarnpher553/tcp-shaker/tcptest"
+	"golang.org/x/sys/unix"
+)
+
+// startBlackholeServer starts a tcptest blackhole server, which is closed by stop.
+func startBlackholeServer(t *testing.T) (addr string, stop func()) {
+	t.Helper()
+	s, err := tcptest.StartBlackholeServer()
+	if err != nil {
+		t.Fatalf("StartBlackholeServer: %v", err)
+	}
+	return s.Addr, func() { s.Close() }
+}
+
+func TestCloseDuringChecks(t *testing.T) {
+	addr, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	for round := 0; round < 5; round++ {
+		c, stop := startChecker(t)
+		const n = 50
+		errs := make(chan error, n)
+		var started sync.WaitGroup
+		started.Add(n)
+		for i := 0; i < n; i++ {
+			go func() {
+				started.Done()
+				errs <- c.CheckAddr(addr, 5*time.Second)
+			}()
+		}
+		started.Wait()
+		// let the checks reach the poller
+		time.Sleep(50 * time.Millisecond)
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			if err := <-errs; err != ErrClosed {
+				t.Fatalf("round %d: in-flight check returned %v, want ErrClosed", round, err)
+			}
+		}
+		if err := c.CheckAddr(addr, time.Second); err != ErrClosed {
+			t.Fatalf("round %d: check after Close returned %v, want ErrClosed", round, err)
+		}
+		if n := c.OpenFiles(); n != 0 {
+			t.Fatalf("round %d: %d files left open", round, n)
+		}
+		stop()
+	}
+}
+
+func TestCloseWhilePolling(t *testing.T) {
+	addr, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	c := NewChecker()
+	loopErr := make(chan error, 1)
+	go func() {
+		loopErr <- c.CheckingLoop(context.Background())
+	}()
+	<-c.WaitReady()
+	checkErr := make(chan error, 1)
+	go func() {
+		checkErr <- c.CheckAddr(addr, 5*time.Second)
+	}()
+	// let the loop block in epoll_wait with the check pending
+	time.Sleep(50 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-loopErr:
+		if err != ErrClosed {
+			t.Fatalf("CheckingLoop returned %v, want ErrClosed", err)
+		}
+	case <-time.After(3 * pollerTimeout):
+		t.Fatal("CheckingLoop did not return after Close")
+	}
+	if err := <-checkErr; err != ErrClosed {
+		t.Fatalf("pending check returned %v, want ErrClosed", err)
+	}
+}
+
+func TestCheckingLoopCanceled(t *testing.T) {
+	addr, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	c := NewChecker()
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	loopErr := make(chan error, 1)
+	go func() {
+		loopErr <- c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+	checkErr := make(chan error, 1)
+	go func() {
+		checkErr <- c.CheckAddr(addr, 5*time.Second)
+	}()
+	// let the check reach the poller
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-loopErr:
+		if err != context.Canceled {
+			t.Fatalf("CheckingLoop returned %v, want context.Canceled", err)
+		}
+	case <-time.After(3 * pollerTimeout):
+		t.Fatal("CheckingLoop did not return after ctx was canceled")
+	}
+	if err := <-checkErr; err != context.Canceled {
+		t.Errorf("pending check returned %v, want context.Canceled", err)
+	}
+	if fd := c.pollerFD(); fd > 0 {
+		t.Errorf("poller fd %d is left open", fd)
+	}
+}
+
+func TestCheckingLoopPollerFailure(t *testing.T) {
+	addr, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	c := NewChecker()
+	defer c.Close()
+	loopErr := make(chan error, 1)
+	go func() {
+		loopErr <- c.CheckingLoop(context.Background())
+	}()
+	<-c.WaitReady()
+	checkErr := make(chan error, 1)
+	go func() {
+		checkErr <- c.CheckAddr(addr, 5*time.Second)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// replace the poller with a pipe, the next epoll_wait fails with EINVAL while nothing is shutting down
+	var p [2]int
+	if err := unix.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(p[1])
+	if err := unix.Dup2(p[0], c.pollerFD()); err != nil {
+		t.Fatal(err)
+	}
+	unix.Close(p[0])
+	select {
+	case err := <-loopErr:
+		var pollerErr *ErrPoller
+		if !errors.As(err, &pollerErr) {
+			t.Fatalf("CheckingLoop returned %v, want *ErrPoller", err)
+		}
+	case <-time.After(3 * pollerTimeout):
+		t.Fatal("CheckingLoop did not return after the poller failed")
+	}
+	if err := <-checkErr; err != ErrPollerClosed {
+		t.Errorf("pending check returned %v, want ErrPollerClosed", err)
+	}
+	if fd := c.pollerFD(); fd > 0 {
+		t.Errorf("poller fd %d is left open", fd)
+	}
+}
+
+func TestPollEventsClosedPoller(t *testing.T) {
+	pollerFd, err := createPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix.Close(pollerFd)
+	_, err = pollEvents(pollerFd, make([]unix.EpollEvent, 1), time.Millisecond, &stats{})
+	if !isPollerClosedError(err) {
+		t.Fatalf("pollEvents on a closed poller returned %v, want EBADF", err)
+	}
+}
+
+// TestConcurrentChecks hammers one Checker from hundreds of goroutines with checks succeeding, being refused
+// and timing out, while it's closed halfway, run it with -race for the result pipes.
+func TestConcurrentChecks(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	refused, err := tcptest.RefusedAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blackhole, stopServer := startBlackholeServer(t)
+	defer stopServer()
+
+	c, stop := startChecker(t)
+	defer stop()
+	const goroutines, checks = 300, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*checks)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < checks; j++ {
+				switch (i + j) % 3 {
+				case 0:
+					errs <- c.CheckAddr(echo.Addr, time.Second)
+				case 1:
+					errs <- c.CheckAddr(refused, time.Second)
+				case 2:
+					// short timeouts race with the events of the other checks
+					errs <- c.CheckAddr(blackhole, time.Duration(j%5)*time.Millisecond)
+				}
+			}
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	c.Close()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil && err != ErrClosed && !IsRefused(err) && !IsTimeout(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if n := c.OpenFiles(); n != 0 {
+		t.Errorf("%d files left open", n)
+	}
+}
+
+func TestConnectTimeoutReason(t *testing.T) {
+	addr, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	c, stop := startChecker(t)
+	defer stop()
+	if err := c.CheckAddr(addr, 50*time.Millisecond); err != ErrTimeout {
+		t.Fatalf("CheckAddr returned %v, want ErrTimeout", err)
+	}
+	result := c.CheckAddrResult(addr, 50*time.Millisecond)
+	if result.Err != ErrTimeout || result.TimeoutReason != ErrNoResponse {
+		t.Fatalf("CheckAddrResult returned %v(%v), want ErrTimeout(ErrNoResponse)", result.Err, result.TimeoutReason)
+	}
+}
+
+// timeWaitPorts returns the local ports of the IPv4 sockets in TIME_WAIT.
+func timeWaitPorts(t *testing.T) map[int]bool {
+	t.Helper()
+	b, err := ioutil.ReadFile("/proc/net/tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := make(map[int]bool)
+	for _, line := range strings.Split(string(b), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != "06" {
+			continue
+		}
+		local := fields[1]
+		port, err := strconv.ParseInt(local[strings.IndexByte(local, ':')+1:], 16, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ports[int(port)] = true
+	}
+	return ports
+}
+
+func TestNoTimeWait(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		timeWait bool
+	}{
+		{"zero linger", nil, false},
+		{"graceful close", []Option{WithGracefulClose()}, true},
+	} {
+		c, stop := startChecker(t, tc.opts...)
+		var ports []int
+		for i := 0; i < 10; i++ {
+			result := c.CheckAddrResult(echo.Addr, time.Second)
+			if result.Err != nil {
+				t.Fatalf("%s: %v", tc.name, result.Err)
+			}
+			ports = append(ports, result.LocalAddr.(*net.TCPAddr).Port)
+		}
+		stop()
+		timeWait := timeWaitPorts(t)
+		var n int
+		for _, port := range ports {
+			if timeWait[port] {
+				n++
+			}
+		}
+		if tc.timeWait && n == 0 {
+			t.Errorf("%s: no local port is in TIME_WAIT", tc.name)
+		}
+		if !tc.timeWait && n != 0 {
+			t.Errorf("%s: %d local ports are in TIME_WAIT", tc.name, n)
+		}
+	}
+}
+
+func TestCloneErrorClassifier(t *testing.T) {
+	refused, err := tcptest.RefusedAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errDown := errors.New("target is down")
+	template := NewChecker()
+	template.SetErrorClassifier(func(errno int) error {
+		if unix.Errno(errno) == unix.ECONNREFUSED {
+			return errDown
+		}
+		return nil
+	})
+	c := template.Clone()
+	stop := runChecker(t, c)
+	defer stop()
+	if err := c.CheckAddr(refused, time.Second); err != errDown {
+		t.Fatalf("clone returned %v, want the error of the classifier", err)
+	}
+}
+
+func TestPanickingErrorClassifier(t *testing.T) {
+	refused, err := tcptest.RefusedAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	c := NewChecker(WithLogger(logger))
+	c.SetErrorClassifier(func(errno int) error { panic("boom") })
+	stop := runChecker(t, c)
+	defer stop()
+	for i := 0; i < 2; i++ {
+		// the default mapping applies, and the loop survives the panic
+		if err := c.CheckAddr(refused, time.Second); !IsRefused(err) {
+			t.Fatalf("CheckAddr returned %v, want it to be refused", err)
+		}
+	}
+	if !logger.contains("error classifier panicked: boom") {
+		t.Errorf("the panic is not logged: %q", logger.lines)
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	addr, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	c, stop := startChecker(t, WithDefaultTimeout(100*time.Millisecond))
+	defer stop()
+
+	start := time.Now()
+	if err := c.CheckAddrContext(context.Background(), addr); err != ErrTimeout {
+		t.Errorf("with no deadline of ctx, CheckAddrContext returned %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("with no deadline of ctx, CheckAddrContext took %v, want the default timeout", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := c.CheckAddrContext(ctx, addr); err != context.DeadlineExceeded {
+		t.Errorf("with a sooner deadline of ctx, CheckAddrContext returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("with a sooner deadline of ctx, CheckAddrContext took %v, want the deadline of ctx", elapsed)
+	}
+}
+
+func TestMinIntervalSkipsNotReady(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c := NewChecker(WithResultCache(16), WithMinInterval(time.Hour))
+	if err := c.CheckAddr(echo.Addr, time.Second); err != ErrNotReady {
+		t.Fatalf("CheckAddr before CheckingLoop returned %v, want ErrNotReady", err)
+	}
+	stop := runChecker(t, c)
+	defer stop()
+	result := c.CheckAddrResult(echo.Addr, time.Second)
+	if result.Err != nil || result.Cached {
+		t.Fatalf("CheckAddrResult returned %v with Cached %v, want a new successful check", result.Err, result.Cached)
+	}
+}
+
+func TestSetEventBatchLimitWhileRunning(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c, stop := startChecker(t)
+	defer stop()
+	c.SetEventBatchLimit(1)
+	// the loop re-reads the limit before polling again, once it has been woken up
+	if err := c.CheckAddr(echo.Addr, time.Second); err != nil {
+		t.Fatalf("CheckAddr returned %v", err)
+	}
+	before := c.Stats()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.CheckAddr(echo.Addr, time.Second); err != nil {
+				t.Errorf("CheckAddr returned %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	after := c.Stats()
+	events, wakeups := after.Events-before.Events, after.EventWakeups-before.EventWakeups
+	if events == 0 || events != wakeups {
+		t.Errorf("%d events in %d wakeups, want at most 1 event per wakeup", events, wakeups)
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	c := NewChecker()
+	var unsupported *ErrUnsupported
+	if err := c.SetNetClass(0x10001); !errors.As(err, &unsupported) {
+		t.Errorf("SetNetClass returned %v, want *ErrUnsupported", err)
+	}
+	if err := c.SetPriority(6); err != nil {
+		t.Errorf("SetPriority(6) returned %v", err)
+	}
+	err := c.SetPriority(0x10001)
+	var privErr *ErrPrivilege
+	if Capabilities().Priority {
+		if err != nil {
+			t.Errorf("SetPriority(0x10001) returned %v", err)
+		}
+	} else if !errors.As(err, &privErr) {
+		t.Errorf("SetPriority(0x10001) without CAP_NET_ADMIN returned %v, want *ErrPrivilege", err)
+	}
+}
+
+func TestIOTimeout(t *testing.T) {
+	// the connections are completed by the kernel but never accepted, so nothing is ever sent back
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, stop := startChecker(t, WithIOTimeout(200*time.Millisecond))
+	defer stop()
+	start := time.Now()
+	result := c.CheckAddrProbe(l.Addr().String(), 5*time.Second, []byte("ping"))
+	if !errors.Is(result.Err, ErrTimeout) {
+		t.Fatalf("CheckAddrProbe returned %v, want ErrTimeout", result.Err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckAddrProbe took %v, want it to be bounded by the I/O timeout", elapsed)
+	}
+}
+
+func TestRecvTimeout(t *testing.T) {
+	// the connections are completed by the kernel but never accepted, so no banner is ever sent
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, stop := startChecker(t, WithRecvTimeout(200*time.Millisecond))
+	defer stop()
+	start := time.Now()
+	if err := c.CheckAddrBanner(l.Addr().String(), time.Second, 5*time.Second, nil); err != ErrReadTimeout {
+		t.Fatalf("CheckAddrBanner returned %v, want ErrReadTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckAddrBanner took %v, want it to be bounded by the receive timeout", elapsed)
+	}
+}
+
+func TestWaitAckedAborted(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// fill the buffers of both ends so that what is written stays in the send queue
+	conn.SetWriteDeadline(time.Now().Add(200 * time.Millisecond))
+	for err == nil {
+		_, err = conn.Write(make([]byte, 1<<16))
+	}
+	rc, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	rc.Control(func(fd uintptr) {
+		err = c.waitAcked(ctx, int(fd), time.Now().Add(5*time.Second))
+	})
+	if err != context.Canceled {
+		t.Errorf("waitAcked with ctx canceled returned %v, want context.Canceled", err)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { c.Close() })
+	start := time.Now()
+	rc.Control(func(fd uintptr) {
+		err = c.waitAcked(context.Background(), int(fd), time.Now().Add(5*time.Second))
+	})
+	if err != ErrClosed {
+		t.Errorf("waitAcked with the Checker closed returned %v, want ErrClosed", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waitAcked took %v after the Checker was closed", elapsed)
+	}
+}
+
+func TestCheckAddrsPartialTimeout(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	refused, err := tcptest.RefusedAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blackhole, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	c, stop := startChecker(t)
+	defer stop()
+
+	results := c.CheckAddrs([]string{refused, echo.Addr, blackhole}, 500*time.Millisecond)
+	if len(results) != 3 {
+		t.Fatalf("CheckAddrs returned %d results, want 3: %v", len(results), results)
+	}
+	var connErr *ErrConnect
+	if err, ok := results[refused]; !ok || !errors.As(err, &connErr) {
+		t.Errorf("refused address resulted in %v, want *ErrConnect", err)
+	}
+	if err, ok := results[echo.Addr]; !ok || err != nil {
+		t.Errorf("accepting address resulted in %v, want nil", err)
+	}
+	if err, ok := results[blackhole]; !ok || !errors.Is(err, ErrTimeout) {
+		t.Errorf("blackholed address resulted in %v, want ErrTimeout", err)
+	}
+	if n := c.OpenFiles(); n != 0 {
+		t.Errorf("%d files left open", n)
+	}
+}
+
+func TestSynchronousConnect(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	tAddr, err := net.ResolveTCPAddr("tcp", echo.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa := &unix.SockaddrInet4{Port: tAddr.Port}
+	copy(sa.Addr[:], tAddr.IP.To4())
+	var recorded []CheckResult
+	var recordedLock sync.Mutex
+	c, stop := startChecker(t, WithOnResult(func(result CheckResult) {
+		recordedLock.Lock()
+		defer recordedLock.Unlock()
+		recorded = append(recorded, result)
+	}))
+	defer stop()
+	var syncConnected bool
+	c.SetConnectHooks(func(fd int) {
+		// connect in blocking mode beforehand, so that the connect of the check finds it connected
+		unix.SetNonblock(fd, false)
+		defer unix.SetNonblock(fd, true)
+		if err := unix.Connect(fd, sa); err != nil {
+			t.Errorf("blocking connect: %v", err)
+		}
+	}, func(fd int, success bool, err error) {
+		syncConnected = success && err == nil
+	})
+
+	result := c.CheckAddrResult(echo.Addr, time.Second)
+	if result.Err != nil {
+		t.Fatalf("CheckAddrResult returned %v", result.Err)
+	}
+	if !syncConnected || !result.Synchronous {
+		t.Fatalf("the connect did not succeed synchronously, Synchronous is %v", result.Synchronous)
+	}
+	if n := c.OpenFiles(); n != 0 {
+		t.Errorf("%d files left open", n)
+	}
+	recordedLock.Lock()
+	if len(recorded) != 1 || !recorded[0].Synchronous || recorded[0].Latency <= 0 {
+		t.Errorf("the result is not recorded properly: %+v", recorded)
+	}
+	recordedLock.Unlock()
+	if timeWaitPorts(t)[result.LocalAddr.(*net.TCPAddr).Port] {
+		t.Error("the socket is in TIME_WAIT, linger was not set to zero")
+	}
+}
+
+func TestFlowInfo(t *testing.T) {
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is unavailable: %v", err)
+	}
+	defer l.Close()
+	c, stop := startChecker(t)
+	defer stop()
+	c.SetFlowInfo(0x12345)
+	flowinfoSend := -1
+	c.SetConnectHooks(nil, func(fd int, success bool, err error) {
+		flowinfoSend, _ = unix.GetsockoptInt(fd, unix.IPPROTO_IPV6, ipv6FlowinfoSend)
+	})
+	if err := c.CheckAddr(l.Addr().String(), time.Second); err != nil {
+		t.Fatalf("CheckAddr with a flow label returned %v", err)
+	}
+	if flowinfoSend != 1 {
+		t.Errorf("IPV6_FLOWINFO_SEND of the probe socket is %d, want 1", flowinfoSend)
+	}
+}
+
+func TestStrictHandshake(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	blackhole, stopServer := startBlackholeServer(t)
+	defer stopServer()
+	for _, tc := range []struct {
+		name    string
+		addr    string
+		timeout bool
+	}{
+		{"accepting", echo.Addr, false},
+		{"handshake never completed", blackhole, true},
+	} {
+		for _, strict := range []bool{false, true} {
+			var opts []Option
+			if strict {
+				opts = append(opts, WithStrictHandshake())
+			}
+			c, stop := startChecker(t, opts...)
+			result := c.CheckAddrResult(tc.addr, 300*time.Millisecond)
+			stop()
+			if tc.timeout && (result.Err != ErrTimeout || result.TimeoutReason != ErrNoResponse) {
+				t.Errorf("%s with strict %v: got %v(%v), want ErrTimeout(ErrNoResponse)", tc.name, strict, result.Err, result.TimeoutReason)
+			}
+			if !tc.timeout && result.Err != nil {
+				t.Errorf("%s with strict %v: got %v, want nil", tc.name, strict, result.Err)
+			}
+		}
+	}
+
+	// the socket of a check reported writable spuriously would still be connecting, which is not confirmed
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	sAddr, _, err := parseSockAddr(blackhole, "tcp", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = unix.Connect(fd, sAddr); err != unix.EINPROGRESS {
+		t.Fatalf("connect to the blackhole server returned %v, want EINPROGRESS", err)
+	}
+	if done, err := handshakeConfirmed(fd); done || err != nil {
+		t.Errorf("handshakeConfirmed of a connecting socket returned %v, %v, want false, nil", done, err)
+	}
+}
+
+func TestDialHoldsSource(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c, stop := startChecker(t, WithSourceCIDR("127.0.0.2/32"))
+	defer stop()
+	conn, err := c.Dial(echo.Addr, time.Second)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	if ip := conn.LocalAddr().(*net.TCPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 2)) {
+		t.Errorf("the connection is from %v, want 127.0.0.2", ip)
+	}
+	if n := c.SourceUsage()["127.0.0.2"]; n != 1 {
+		t.Errorf("%d sockets counted on the source address of the open connection, want 1", n)
+	}
+	conn.Close()
+	conn.Close()
+	if n := c.SourceUsage()["127.0.0.2"]; n != 0 {
+		t.Errorf("%d sockets counted on the source address after the connection is closed, want 0", n)
+	}
+}
+
+func TestWarmupRacingClose(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		c, stop := startChecker(t)
+		done := make(chan error, 1)
+		go func() {
+			done <- c.Warmup()
+		}()
+		stop()
+		if err := <-done; err != nil && err != ErrClosed && err != ErrNotReady {
+			t.Fatalf("Warmup racing Close returned %v", err)
+		}
+	}
+}
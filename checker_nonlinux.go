@@ -4,32 +4,79 @@ package tcp
 
 import (
 	"context"
+	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 // Checker is a fake implementation.
 type Checker struct {
-	zeroLinger bool
-	isReady    chan struct{}
+	config
+	fds           *fdLimiter
+	results       *resultCache
+	recent        *recentChecks
+	rtts          *rttHistory
+	warned        sync.Map
+	warmedHosts   sync.Map
+	stats         stats
+	sink          jsonSink
+	zeroLinger    bool
+	isReady       chan struct{}
+	closing       chan struct{}
+	closed        int32
+	onReadyChange func(ready bool)
 }
 
-// NewChecker creates a Checker with linger set to zero.
-func NewChecker() *Checker {
-	return NewCheckerZeroLinger(true)
+// NewChecker creates a Checker with linger set to zero, so that the sockets of checks are reset on close,
+// they never enter TIME_WAIT and their local ports are reusable right away.
+func NewChecker(opts ...Option) *Checker {
+	return NewCheckerZeroLinger(true, opts...)
 }
 
 // NewCheckerZeroLinger creates a Checker with zeroLinger set to given value.
-func NewCheckerZeroLinger(zeroLinger bool) *Checker {
+func NewCheckerZeroLinger(zeroLinger bool, opts ...Option) *Checker {
+	conf := newConfig(opts)
+	return newChecker(conf, zeroLinger && !conf.gracefulClose)
+}
+
+func newChecker(conf config, zeroLinger bool) *Checker {
 	isReady := make(chan struct{})
 	close(isReady)
-	return &Checker{zeroLinger: zeroLinger, isReady: isReady}
+	c := &Checker{
+		config:     conf,
+		fds:        newFDLimiter(conf.maxOpenFiles),
+		zeroLinger: zeroLinger,
+		isReady:    isReady,
+		closing:    make(chan struct{}),
+	}
+	if conf.maxTargets > 0 {
+		c.results = newResultCache(conf.maxTargets)
+		if conf.minInterval > 0 {
+			c.recent = newRecentChecks(conf.minInterval, conf.maxTargets)
+		}
+	}
+	if conf.adaptiveK > 0 {
+		c.rtts = newRTTHistory(conf.adaptiveFloor, conf.adaptiveK)
+	}
+	if !zeroLinger {
+		c.warnTimeWait()
+	}
+	return c
+}
+
+// Clone creates a Checker with the same options, but nothing else is shared:
+// it has zeroed stats, an empty result cache and no JSON sink.
+func (c *Checker) Clone() *Checker {
+	return newChecker(c.config, c.zeroLinger)
 }
 
-// CheckingLoop is unnecessary on this platform.
+// CheckingLoop is unnecessary on this platform, it blocks until ctx is done and returns ctx.Err().
 func (c *Checker) CheckingLoop(ctx context.Context) error {
 	<-ctx.Done()
-	return nil
+	return ctx.Err()
 }
 
 // CheckAddr performs a TCP check with given TCP address and timeout.
@@ -41,29 +88,246 @@ func (c *Checker) CheckAddr(addr string, timeout time.Duration) error {
 
 // CheckAddrZeroLinger is CheckerAddr with a zeroLinger parameter.
 func (c *Checker) CheckAddrZeroLinger(addr string, timeout time.Duration, zeroLinger bool) error {
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if !zeroLinger {
+		c.warnTimeWait()
+	}
+	return c.checkAddr(context.Background(), addr, timeout, zeroLinger).Err
+}
+
+// CheckAddrResult is like CheckAddr but returns a CheckResult with the details of the check.
+func (c *Checker) CheckAddrResult(addr string, timeout time.Duration) *CheckResult {
+	return c.checkAddr(context.Background(), addr, timeout, c.zeroLinger)
+}
+
+// CheckAddrContext is like CheckAddr but the check is limited by ctx instead of a timeout,
+// ctx.Err() is returned if ctx is done before the check is finished.
+// If a default timeout is set by WithDefaultTimeout, the sooner of it and the deadline of ctx applies:
+// ErrTimeout(or one of its subcategories) is returned if the timeout fires first, ctx.Err() otherwise.
+func (c *Checker) CheckAddrContext(ctx context.Context, addr string) error {
+	return c.checkAddr(ctx, addr, c.contextTimeout(), c.zeroLinger).Err
+}
+
+// connectAddr performs a check of addr, which is spaced by checkAddr if WithMinInterval is set.
+func (c *Checker) connectAddr(ctx context.Context, addr string, timeout time.Duration, zeroLinger bool) *CheckResult {
+	result := newCheckResult(addr)
+	result.ID = checkID(ctx)
+	defer c.recordResult(result)
+	if c.IsClosed() {
+		result.Err = ErrClosed
+		return result
+	}
+	result.Timeout = c.adaptTimeout(addr, timeout)
+	if result.Timeout < 0 {
+		// the deadline has already passed
+		result.Err = ErrTimeout
+		return result
+	}
+	deadline := time.Now().Add(result.Timeout)
+	if result.Err = c.fds.acquire(ctx, deadline); result.Err != nil {
+		return result
+	}
+	defer c.fds.release()
+	if result.Err = c.delayConnect(ctx, deadline); result.Err != nil {
+		return result
+	}
+	startedAt := time.Now()
+	if c.onConnectStart != nil {
+		c.safeCall("OnConnectStart", func() { c.onConnectStart(addr, startedAt) })
+	}
+	network := c.network
+	if network == "" {
+		network = "tcp"
+	}
+	dialer := c.newDialer(time.Until(deadline))
+	conn, err := dialer.DialContext(ctx, network, addr)
+	result.Latency = time.Since(startedAt)
 	if conn != nil {
+		result.LocalAddr = conn.LocalAddr()
+		result.RemoteAddr = conn.RemoteAddr()
 		if zeroLinger {
 			// Simply ignore the error since this is a fake implementation.
 			conn.(*net.TCPConn).SetLinger(0)
 		}
 		conn.Close()
 	}
+	if opErr, ok := err.(*net.OpError); ok && opErr.Addr != nil {
+		result.RemoteAddr = opErr.Addr
+	}
+	if tAddr, ok := result.RemoteAddr.(*net.TCPAddr); ok && tAddr != nil {
+		result.Network = "tcp6"
+		if tAddr.IP.To4() != nil {
+			result.Network = "tcp4"
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		result.Err = ctx.Err()
+		return result
+	}
+	result.Err = normalizeDialError(familyError(err, network, addr))
+	return result
+}
+
+// newDialer returns a copy of the dialer set by WithFallbackDialer with its Timeout capped by timeout.
+func (c *Checker) newDialer(timeout time.Duration) net.Dialer {
+	var dialer net.Dialer
+	if c.fallbackDialer != nil {
+		dialer = *c.fallbackDialer
+	}
+	if dialer.Timeout <= 0 || dialer.Timeout > timeout {
+		dialer.Timeout = timeout
+	}
+	return dialer
+}
+
+// normalizeDialError converts errors returned by net package to the ones of this package,
+// so that a failed connect is reported as *ErrConnect with the error number like on Linux.
+func normalizeDialError(err error) error {
 	if opErr, ok := err.(*net.OpError); ok {
 		if opErr.Timeout() {
 			return ErrTimeout
 		}
+		var errno syscall.Errno
+		if opErr.Op == "dial" && errors.As(opErr.Err, &errno) {
+			return &ErrConnect{errno}
+		}
 	}
 	return err
 }
 
+// SetCongestionControl is not supported on this platform.
+func (c *Checker) SetCongestionControl(name string) error {
+	return &ErrUnsupported{Option: "TCP_CONGESTION " + name}
+}
+
+// SetMark is not supported on this platform.
+func (c *Checker) SetMark(mark uint32) error {
+	return &ErrUnsupported{Option: "SO_MARK"}
+}
+
+// SetRoutingTable is not supported on this platform.
+func (c *Checker) SetRoutingTable(id int) error {
+	return &ErrUnsupported{Option: "SO_MARK"}
+}
+
+// SourceUsage returns nil since WithSourceCIDR is ignored on this platform.
+func (c *Checker) SourceUsage() map[string]int { return nil }
+
+// BindToDevice is not supported on this platform.
+func (c *Checker) BindToDevice(name string) error {
+	return &ErrUnsupported{Option: "SO_BINDTODEVICE"}
+}
+
+// CheckLoopback is not supported on this platform.
+func (c *Checker) CheckLoopback(listenerFd int, timeout time.Duration) error {
+	return &ErrUnsupported{Option: "CheckLoopback"}
+}
+
+// CheckFD is not supported on this platform.
+func (c *Checker) CheckFD(fd int, timeout time.Duration) error {
+	return &ErrUnsupported{Option: "CheckFD"}
+}
+
+// SetNetns is not supported on this platform.
+func (c *Checker) SetNetns(path string) error {
+	return &ErrUnsupported{Option: "setns"}
+}
+
+// SetNetClass is not supported.
+func (c *Checker) SetNetClass(classid uint32) error {
+	return &ErrUnsupported{Option: "net_cls classid"}
+}
+
+// SetPriority is not supported on this platform.
+func (c *Checker) SetPriority(priority uint32) error {
+	return &ErrUnsupported{Option: "SO_PRIORITY"}
+}
+
+// SetNoDelay is a no-op since TCP_NODELAY is always enabled by net package on this platform.
+func (c *Checker) SetNoDelay(noDelay bool) {}
+
+// SetFlowInfo is a no-op since connect is done by net package on this platform.
+func (c *Checker) SetFlowInfo(flowinfo uint32) {}
+
+// SetReuseAddr is a no-op since probe sockets are never bound on this platform.
+func (c *Checker) SetReuseAddr(reuse bool) {}
+
+// SetReusePort is a no-op since probe sockets are never bound on this platform.
+func (c *Checker) SetReusePort(reuse bool) {}
+
+// SetErrorClassifier is a no-op since errors are returned by net package on this platform.
+func (c *Checker) SetErrorClassifier(classify func(errno int) error) {}
+
+// SetEventBatchLimit is a no-op since there is no poller on this platform.
+func (c *Checker) SetEventBatchLimit(n int) {}
+
+// SetConnectHooks is a no-op since connect is done by net package on this platform.
+func (c *Checker) SetConnectHooks(before func(fd int), after func(fd int, success bool, err error)) {}
+
+// SetEventHook is a no-op since there is no poller on this platform.
+func (c *Checker) SetEventHook(hook func(fd int, events uint32, err error)) {}
+
+// WarmupSockets is a no-op since sockets are created by net package on this platform.
+func (c *Checker) WarmupSockets(n int, network string, ttl time.Duration) error { return nil }
+
+// Trim is a no-op since no socket is pooled on this platform.
+func (c *Checker) Trim() {}
+
+// warmupSocket is unnecessary on this platform, ErrClosed is returned if the Checker is closed.
+func (c *Checker) warmupSocket() error {
+	if c.IsClosed() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// OpenFiles returns the number of connections currently opened by the Checker.
+func (c *Checker) OpenFiles() int {
+	return c.fds.count()
+}
+
 // IsReady is always true on this platform.
 func (c *Checker) IsReady() bool { return true }
 
+// LastLoopTick returns the current time since there is no loop to be wedged on this platform.
+func (c *Checker) LastLoopTick() time.Time { return time.Now() }
+
+// Healthy reports whether the Checker is not closed, since there is no loop on this platform.
+func (c *Checker) Healthy() bool { return !c.IsClosed() }
+
 // WaitReady returns a closed chan on this platform.
 func (c *Checker) WaitReady() <-chan struct{} {
 	return c.isReady
 }
 
-// Close is unnecessary on this platform.
-func (c *Checker) Close() error { return nil }
+// Close stops writing results to the sink set by SetJSONSink and aborts the checks delayed by WithConnectDelay,
+// nothing else is necessary on this platform.
+func (c *Checker) Close() error {
+	c.sink.setWriter(nil)
+	if atomic.SwapInt32(&c.closed, 1) == 0 {
+		close(c.closing)
+		if c.onReadyChange != nil {
+			go c.safeCall("OnReadyChange", func() { c.onReadyChange(false) })
+		}
+	}
+	return nil
+}
+
+// OnReadyChange sets a function to be called with false in a separate goroutine once Close is called,
+// since the Checker is always ready on this platform.
+// NOTE: This must be called before Close.
+func (c *Checker) OnReadyChange(f func(ready bool)) {
+	c.onReadyChange = f
+}
+
+// IsClosed reports whether Close was called.
+func (c *Checker) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+// State returns StateClosed if Close was called, StateRunning otherwise since no loop is necessary on this platform.
+func (c *Checker) State() State {
+	if c.IsClosed() {
+		return StateClosed
+	}
+	return StateRunning
+}
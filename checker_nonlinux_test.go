@@ -0,0 +1,27 @@
+// +build !linux
+
+package tcp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string { return "i/o timeout" }
+func (timeoutErr) Timeout() bool { return true }
+
+func TestNormalizeDialError(t *testing.T) {
+	refused := normalizeDialError(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)})
+	var connErr *ErrConnect
+	if !errors.As(refused, &connErr) || !IsRefused(refused) {
+		t.Errorf("refused dial is normalized to %v(%T), want *ErrConnect", refused, refused)
+	}
+	if err := normalizeDialError(&net.OpError{Op: "dial", Net: "tcp", Err: timeoutErr{}}); err != ErrTimeout {
+		t.Errorf("timed out dial is normalized to %v, want ErrTimeout", err)
+	}
+}
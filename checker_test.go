@@ -0,0 +1,249 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jarnpher553/tcp-shaker/tcptest"
+)
+
+// testLogger records the lines logged by a Checker.
+type testLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+// contains reports whether a line containing substr was logged.
+func (l *testLogger) contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// startChecker creates a Checker with opts and starts its CheckingLoop,
+// stop cancels the loop and closes the Checker.
+func startChecker(t *testing.T, opts ...Option) (c *Checker, stop func()) {
+	t.Helper()
+	c = NewChecker(opts...)
+	return c, runChecker(t, c)
+}
+
+// runChecker starts the CheckingLoop of c, stop cancels the loop and closes c.
+func runChecker(t *testing.T, c *Checker) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	loopErr := make(chan error, 1)
+	go func() {
+		loopErr <- c.CheckingLoop(ctx)
+	}()
+	select {
+	case <-c.WaitReady():
+	case err := <-loopErr:
+		cancel()
+		t.Fatalf("CheckingLoop: %v", err)
+	}
+	return func() {
+		cancel()
+		c.Close()
+	}
+}
+
+func TestNegativeTimeout(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c, stop := startChecker(t)
+	defer stop()
+	for _, timeout := range []time.Duration{-time.Nanosecond, -time.Hour} {
+		start := time.Now()
+		if err := c.CheckAddr(echo.Addr, timeout); err != ErrTimeout {
+			t.Errorf("CheckAddr with timeout %v returned %v, want ErrTimeout", timeout, err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("CheckAddr with timeout %v took %v, want it to fail immediately", timeout, elapsed)
+		}
+	}
+	if n := c.OpenFiles(); n != 0 {
+		t.Errorf("%d files left open", n)
+	}
+}
+
+func TestRefused(t *testing.T) {
+	refused, err := tcptest.RefusedAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, stop := startChecker(t)
+	defer stop()
+	err = c.CheckAddr(refused, time.Second)
+	if !IsRefused(err) || IsTimeout(err) {
+		t.Fatalf("CheckAddr returned %v, want it to be refused", err)
+	}
+	var connErr *ErrConnect
+	if !errors.As(err, &connErr) {
+		t.Errorf("CheckAddr returned %T, want *ErrConnect", err)
+	}
+	if kind := c.Classify(err); kind != FailureRefused {
+		t.Errorf("Classify(%v) = %v, want %v", err, kind, FailureRefused)
+	}
+}
+
+func TestPanickingOnResult(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	logger := &testLogger{}
+	c, stop := startChecker(t, WithLogger(logger), WithOnResult(func(CheckResult) { panic("boom") }))
+	for i := 0; i < 20; i++ {
+		if err := c.CheckAddr(echo.Addr, time.Second); err != nil {
+			t.Fatalf("CheckAddr returned %v", err)
+		}
+	}
+	if n := c.OpenFiles(); n != 0 {
+		t.Errorf("%d files left open", n)
+	}
+	if !logger.contains("OnResult panicked: boom") {
+		t.Errorf("the panic is not logged: %q", logger.lines)
+	}
+	closed := make(chan struct{})
+	go func() {
+		stop()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close is blocked by checks never finished")
+	}
+}
+
+func TestMinIntervalSkipsCanceled(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c, stop := startChecker(t, WithResultCache(16), WithMinInterval(time.Hour))
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.CheckAddrContext(ctx, echo.Addr); err != context.Canceled {
+		t.Fatalf("CheckAddrContext with a canceled context returned %v, want context.Canceled", err)
+	}
+	result := c.CheckAddrResult(echo.Addr, time.Second)
+	if result.Err != nil || result.Cached {
+		t.Fatalf("CheckAddrResult returned %v with Cached %v, want a new successful check", result.Err, result.Cached)
+	}
+	result = c.CheckAddrResult(echo.Addr, time.Second)
+	if result.Err != nil || !result.Cached {
+		t.Fatalf("CheckAddrResult returned %v with Cached %v, want the cached success", result.Err, result.Cached)
+	}
+}
+
+func TestMinIntervalCoalesces(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	var connects int32
+	c, stop := startChecker(t,
+		WithResultCache(16),
+		WithMinInterval(time.Hour),
+		WithConnectDelay(100*time.Millisecond),
+		WithOnConnectStart(func(string, time.Time) { atomic.AddInt32(&connects, 1) }),
+	)
+	defer stop()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.CheckAddr(echo.Addr, time.Second); err != nil {
+				t.Errorf("CheckAddr returned %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&connects); n != 1 {
+		t.Errorf("%d connects for concurrent checks of the same address, want 1", n)
+	}
+}
+
+func TestCheckAddrProbeReadNegativeMaxRead(t *testing.T) {
+	c := NewChecker()
+	defer c.Close()
+	received, err := c.CheckAddrProbeRead("127.0.0.1:1", time.Second, nil, -1)
+	if err == nil || received != nil {
+		t.Fatalf("CheckAddrProbeRead with negative maxRead returned %q, %v, want an error", received, err)
+	}
+}
+
+func TestCheckAfterClose(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c, stop := startChecker(t)
+	stop()
+	if err := c.CheckAddr(echo.Addr, time.Second); err != ErrClosed {
+		t.Errorf("CheckAddr after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseDuringConnectDelay(t *testing.T) {
+	echo, err := tcptest.StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	c, stop := startChecker(t, WithConnectDelay(5*time.Second))
+	checkErr := make(chan error, 1)
+	go func() {
+		checkErr <- c.CheckAddr(echo.Addr, 10*time.Second)
+	}()
+	// let the check start waiting for the delay
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	select {
+	case err := <-checkErr:
+		if err != ErrClosed {
+			t.Errorf("delayed check returned %v after Close, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("delayed check did not return after Close")
+	}
+}
+
+func TestWarmupAfterClose(t *testing.T) {
+	c, stop := startChecker(t)
+	if err := c.Warmup(); err != nil {
+		t.Fatalf("Warmup returned %v", err)
+	}
+	stop()
+	if err := c.Warmup(); err != ErrClosed {
+		t.Errorf("Warmup after Close returned %v, want ErrClosed", err)
+	}
+}
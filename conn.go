@@ -0,0 +1,30 @@
+package tcp
+
+import (
+	"net"
+	"time"
+)
+
+// CheckConn checks a connection created by newConn, which may go through any transport
+// (e.g. an SSH local forward), by a zero-byte write which fails if the connection is already reset,
+// followed by waiting for the socket to be ready like CheckFD on Linux, which catches a pending RST.
+// The connection is closed afterwards, with linger set to zero like the checks if it's a *net.TCPConn.
+func (c *Checker) CheckConn(newConn func() (net.Conn, error), timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	conn, err := newConn()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if tcpConn, ok := conn.(*net.TCPConn); ok && c.zeroLinger {
+			tcpConn.SetLinger(0)
+		}
+		conn.Close()
+	}()
+
+	conn.SetWriteDeadline(deadline)
+	if _, err = conn.Write(nil); err != nil {
+		return err
+	}
+	return c.checkConnReady(conn, time.Until(deadline))
+}
@@ -0,0 +1,26 @@
+package tcp
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+// checkConnReady checks the socket of conn via CheckFD, conn is left as is if it has no socket.
+func (c *Checker) checkConnReady(conn net.Conn, timeout time.Duration) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var checkErr error
+	if err = rc.Control(func(fd uintptr) {
+		checkErr = c.CheckFD(int(fd), timeout)
+	}); err != nil {
+		return err
+	}
+	return checkErr
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package tcp
+
+import (
+	"net"
+	"time"
+)
+
+// checkConnReady is unnecessary on this platform since CheckFD is not supported.
+func (c *Checker) checkConnReady(conn net.Conn, timeout time.Duration) error { return nil }
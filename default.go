@@ -0,0 +1,62 @@
+package tcp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultChecker is the Checker used by Check, it's created on first use.
+var defaultChecker struct {
+	sync.Mutex
+	c    *Checker
+	stop context.CancelFunc
+}
+
+// Check checks addr with the default Checker, which is created by NewChecker and has its CheckingLoop
+// started on first use, it's handy for scripts and simple uses. The error of starting the loop
+// is returned if there is one, in which case the next call tries again.
+func Check(addr string, timeout time.Duration) error {
+	c, err := getDefaultChecker()
+	if err != nil {
+		return err
+	}
+	return c.CheckAddr(addr, timeout)
+}
+
+// CloseDefault stops the CheckingLoop of the default Checker and closes it,
+// a new one is created if Check is called afterwards.
+func CloseDefault() error {
+	defaultChecker.Lock()
+	defer defaultChecker.Unlock()
+	if defaultChecker.c == nil {
+		return nil
+	}
+	defaultChecker.stop()
+	err := defaultChecker.c.Close()
+	defaultChecker.c, defaultChecker.stop = nil, nil
+	return err
+}
+
+func getDefaultChecker() (*Checker, error) {
+	defaultChecker.Lock()
+	defer defaultChecker.Unlock()
+	if defaultChecker.c != nil {
+		return defaultChecker.c, nil
+	}
+	c := NewChecker()
+	ctx, stop := context.WithCancel(context.Background())
+	loopErr := make(chan error, 1)
+	go func() {
+		loopErr <- c.CheckingLoop(ctx)
+	}()
+	select {
+	case <-c.WaitReady():
+	case err := <-loopErr:
+		stop()
+		c.Close()
+		return nil, err
+	}
+	defaultChecker.c, defaultChecker.stop = c, stop
+	return c, nil
+}
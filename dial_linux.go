@@ -0,0 +1,62 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Dial connects to addr the same way as CheckAddr does, but returns the established connection
+// with keepalive enabled instead of closing it, which saves a second connect if the connection is to be used.
+// NOTE: The connection is owned by the caller, it's not counted by WithMaxOpenFiles.
+// With WithSourceCIDR, its source address is counted by SourceUsage until it's closed,
+// in which case the connection is not a *net.TCPConn but has all of its methods.
+func (c *Checker) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	conn, result := c.dial(addr, timeout)
+	return conn, result.Err
+}
+
+// dial is Dial returning the result of the check as well.
+func (c *Checker) dial(addr string, timeout time.Duration) (net.Conn, *CheckResult) {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return nil, result
+	}
+	defer c.inflight.Done()
+
+	var conn net.Conn
+	// linger is left as is since the connection is closed by the caller
+	result.Err = c.doCheckAddr(context.Background(), result, timeout, false, func(fd int) (err error) {
+		if conn, err = fileConn(fd); err == nil {
+			conn = c.holdSource(conn)
+		}
+		return err
+	})
+	if result.Err != nil {
+		return nil, result
+	}
+	return conn, result
+}
+
+// fileConn returns a net.Conn with keepalive enabled for a copy of given fd, fd itself is left open.
+func fileConn(fd int) (net.Conn, error) {
+	dupFd, err := unix.Dup(fd)
+	if err != nil {
+		return nil, os.NewSyscallError("dup", err)
+	}
+	f := os.NewFile(uintptr(dupFd), "")
+	// net.FileConn duplicates the fd again
+	defer f.Close()
+	conn, err := net.FileConn(f)
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetKeepAlive(true)
+	}
+	return conn, nil
+}
@@ -0,0 +1,33 @@
+// +build !linux
+
+package tcp
+
+import (
+	"net"
+	"time"
+)
+
+// Dial connects to addr the same way as CheckAddr does, but returns the established connection
+// with keepalive enabled instead of closing it, which saves a second connect if the connection is to be used.
+// NOTE: The connection is owned by the caller, it's not counted by WithMaxOpenFiles.
+func (c *Checker) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	conn, result := c.dial(addr, timeout)
+	return conn, result.Err
+}
+
+// dial is Dial returning the result of the check as well.
+func (c *Checker) dial(addr string, timeout time.Duration) (net.Conn, *CheckResult) {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	startedAt := time.Now()
+	dialer := c.newDialer(timeout)
+	conn, err := dialer.Dial("tcp", addr)
+	result.Latency = time.Since(startedAt)
+	if err != nil {
+		result.Err = normalizeDialError(err)
+		return nil, result
+	}
+	result.LocalAddr = conn.LocalAddr()
+	conn.(*net.TCPConn).SetKeepAlive(true)
+	return conn, result
+}
@@ -1,21 +1,261 @@
 package tcp
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
 
 // ErrTimeout indicates I/O timeout
-var ErrTimeout = &timeoutError{}
+var ErrTimeout = &timeoutError{"I/O timeout"}
 
-type timeoutError struct{}
+// ErrConnectTimeout indicates timeout while connecting, it matches ErrTimeout with errors.Is.
+var ErrConnectTimeout = &timeoutError{"connect timeout"}
 
-func (e *timeoutError) Error() string   { return "I/O timeout" }
+// ErrReadTimeout indicates timeout while reading from an established connection,
+// it matches ErrTimeout with errors.Is.
+var ErrReadTimeout = &timeoutError{"read timeout"}
+
+// ErrNoResponse indicates timeout while connecting without any response from the remote,
+// i.e. the SYN is not answered. It's reported by CheckResult.TimeoutReason and matches ErrTimeout with errors.Is.
+var ErrNoResponse = &timeoutError{"connect timeout: no response"}
+
+// ErrNetworkTimeout indicates timeout while connecting, for which the kernel has given up
+// retransmitting as well(ETIMEDOUT). It's reported by CheckResult.TimeoutReason and matches ErrTimeout with errors.Is.
+var ErrNetworkTimeout = &timeoutError{"connect timeout: network timed out"}
+
+type timeoutError struct {
+	msg string
+}
+
+func (e *timeoutError) Error() string   { return e.msg }
 func (e *timeoutError) Timeout() bool   { return true }
 func (e *timeoutError) Temporary() bool { return true }
 
+// Is makes all timeout errors match ErrTimeout.
+func (e *timeoutError) Is(target error) bool { return target == ErrTimeout }
+
 // ErrCheckerAlreadyStarted indicates there is another instance of CheckingLoop running.
 var ErrCheckerAlreadyStarted = errors.New("Checker was already started")
 
+// ErrPollerClosed indicates the poller was closed before the check is resolved.
+var ErrPollerClosed = errors.New("poller was closed")
+
+// ErrPoller indicates CheckingLoop stopped due to a failure of the poller,
+// the loop may be restarted by calling CheckingLoop again.
+type ErrPoller struct {
+	Op  string
+	Err error
+}
+
+func (e *ErrPoller) Error() string { return e.Op + ": " + e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *ErrPoller) Unwrap() error { return e.Err }
+
+// ErrInternal indicates the check failed due to the Checker itself rather than the target,
+// e.g. SO_ERROR of the socket could not be retrieved, so it's worth retrying.
+type ErrInternal struct {
+	Op  string
+	Err error
+}
+
+func (e *ErrInternal) Error() string { return e.Op + ": " + e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *ErrInternal) Unwrap() error { return e.Err }
+
+// Is makes a bind failed with EADDRINUSE match ErrAddrInUse.
+func (e *ErrInternal) Is(target error) bool {
+	return target == ErrAddrInUse && e.Err == syscall.EADDRINUSE
+}
+
+// IsInternal reports whether err indicates the check failed due to the Checker itself.
+func IsInternal(err error) bool {
+	var internal *ErrInternal
+	return errors.As(err, &internal)
+}
+
+// ErrNoAddressForFamily indicates Host has no address of Network("tcp4" or "tcp6") required by WithNetwork,
+// although it's resolved successfully.
+type ErrNoAddressForFamily struct {
+	Host    string
+	Network string
+}
+
+func (e *ErrNoAddressForFamily) Error() string {
+	family := "IPv4"
+	if e.Network == "tcp6" {
+		family = "IPv6"
+	}
+	return "no " + family + " address for host " + e.Host
+}
+
+// Is makes e match target of the same type, whose empty fields match any value,
+// e.g. errors.Is(err, &ErrNoAddressForFamily{}) reports whether err is an ErrNoAddressForFamily of any host.
+func (e *ErrNoAddressForFamily) Is(target error) bool {
+	t, ok := target.(*ErrNoAddressForFamily)
+	return ok && (t.Host == "" || t.Host == e.Host) && (t.Network == "" || t.Network == e.Network)
+}
+
+// ErrNotReady indicates the check was issued before CheckingLoop is running.
+var ErrNotReady = errors.New("Checker is not ready, CheckingLoop is not running")
+
+// ErrFDLimit indicates no socket could be opened within the limit set by WithMaxOpenFiles.
+var ErrFDLimit = errors.New("too many open files in Checker")
+
+// ErrClosed indicates the Checker was closed.
+var ErrClosed = errors.New("Checker was closed")
+
 // ErrConnect is an error occurs while connecting to the host
 // To get the detail of underlying error, lookup ErrorCode() in 'man 2 connect'
 type ErrConnect struct {
 	error
 }
+
+// Unwrap returns the underlying error.
+func (e *ErrConnect) Unwrap() error { return e.error }
+
+// Is makes a connect blocked by local policy match ErrBlocked,
+// and a connect failed with EADDRINUSE match ErrAddrInUse.
+func (e *ErrConnect) Is(target error) bool {
+	switch target {
+	case ErrBlocked:
+		return isBlockedErrno(e.error)
+	case ErrAddrInUse:
+		return e.error == syscall.EADDRINUSE
+	}
+	return false
+}
+
+// ErrBlocked indicates the connect was blocked locally(e.g. by firewall rules) rather than
+// refused by the remote, it's matched by a connect failed with EACCES or EPERM via errors.Is.
+var ErrBlocked = errors.New("connect was blocked by local policy")
+
+// ErrAddrInUse indicates the local address of a probe socket collided with another socket(EADDRINUSE),
+// either on binding it(e.g. to a source address set by WithSourceCIDR) or on connecting from it,
+// it's matched by such a failure via errors.Is. Unlike a failure of the target, the remedy is to retry later,
+// or to enable SetReuseAddr, which allows binding to a local port still in TIME_WAIT.
+var ErrAddrInUse = errors.New("local address already in use")
+
+// IsBlocked reports whether err indicates the connect was blocked locally.
+func IsBlocked(err error) bool {
+	var errno syscall.Errno
+	return errors.Is(err, ErrBlocked) || errors.As(err, &errno) && isBlockedErrno(errno)
+}
+
+// IsTimeout reports whether err indicates the check timed out, including the subcategories of ErrTimeout.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// IsRefused reports whether err indicates the connection was refused by the remote.
+func IsRefused(err error) bool {
+	var errno syscall.Errno
+	return errors.As(err, &errno) && errno == syscall.ECONNREFUSED
+}
+
+func isBlockedErrno(err error) bool {
+	return err == syscall.EACCES || err == syscall.EPERM
+}
+
+// errFdRegistered indicates an fd is registered twice, which should never happen.
+var errFdRegistered = errors.New("fd is already registered for another check")
+
+// ErrCanceled indicates the check was canceled by CheckHandle.Cancel.
+var ErrCanceled = errors.New("check was canceled")
+
+// ErrClosedAfterConnect indicates the server closed the connection within the grace period
+// set by WithPostConnectGrace.
+var ErrClosedAfterConnect = errors.New("connection was closed by the server right after connect")
+
+// ErrBannerMismatch indicates the banner sent by the server is not as expected.
+var ErrBannerMismatch = errors.New("banner mismatch")
+
+// ErrBacklogFull indicates the connection was not accepted by the server in time,
+// which is the case when the accept queue of the listener is saturated.
+var ErrBacklogFull = errors.New("connection was not accepted, the backlog of the server may be full")
+
+// ErrLineTooLong indicates a line sent by the server exceeds 4096 bytes.
+var ErrLineTooLong = errors.New("line is too long")
+
+// ErrPrivilege indicates a socket option could not be set due to lack of privilege(e.g. CAP_NET_ADMIN).
+type ErrPrivilege struct {
+	Option string
+	Err    error
+}
+
+func (e *ErrPrivilege) Error() string {
+	return "insufficient privilege to set " + e.Option + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrPrivilege) Unwrap() error { return e.Err }
+
+// ErrNetns indicates the network namespace set by SetNetns could not be entered.
+type ErrNetns struct {
+	Path string
+	Err  error
+}
+
+func (e *ErrNetns) Error() string {
+	return "unable to enter network namespace " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrNetns) Unwrap() error { return e.Err }
+
+// AddrErrors contains the failed addresses and their errors respectively.
+type AddrErrors struct {
+	Addrs []string
+	Errs  []error
+}
+
+func (e *AddrErrors) add(addr string, err error) {
+	e.Addrs = append(e.Addrs, addr)
+	e.Errs = append(e.Errs, err)
+}
+
+func (e *AddrErrors) String() string {
+	failures := make([]string, len(e.Addrs))
+	for i, addr := range e.Addrs {
+		failures[i] = addr + ": " + e.Errs[i].Error()
+	}
+	return strings.Join(failures, "; ")
+}
+
+// ErrAllFailed indicates none of the addresses passed the check.
+type ErrAllFailed struct {
+	AddrErrors
+}
+
+func (e *ErrAllFailed) Error() string {
+	if len(e.Addrs) == 0 {
+		return "no address to check"
+	}
+	return fmt.Sprintf("all %d addresses failed: %s", len(e.Addrs), e.AddrErrors.String())
+}
+
+// ErrSomeFailed indicates some of the addresses failed the check.
+type ErrSomeFailed struct {
+	AddrErrors
+}
+
+func (e *ErrSomeFailed) Error() string {
+	return fmt.Sprintf("%d addresses failed: %s", len(e.Addrs), e.AddrErrors.String())
+}
+
+// ErrUnsupported indicates a socket option is not available on the running
+// platform or kernel, or the process lacks the privilege to set it.
+type ErrUnsupported struct {
+	Option string
+	Err    error
+}
+
+func (e *ErrUnsupported) Error() string {
+	if e.Err == nil {
+		return e.Option + " is not supported on this platform"
+	}
+	return e.Option + " is not supported: " + e.Err.Error()
+}
@@ -0,0 +1,30 @@
+package tcp
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestErrBlocked(t *testing.T) {
+	for _, tc := range []struct {
+		errno   syscall.Errno
+		blocked bool
+	}{
+		{syscall.EACCES, true},
+		{syscall.EPERM, true},
+		{syscall.ECONNREFUSED, false},
+		{syscall.ETIMEDOUT, false},
+	} {
+		err := &ErrConnect{tc.errno}
+		if got := errors.Is(err, ErrBlocked); got != tc.blocked {
+			t.Errorf("errors.Is(%v, ErrBlocked) = %v, want %v", tc.errno, got, tc.blocked)
+		}
+		if got := IsBlocked(err); got != tc.blocked {
+			t.Errorf("IsBlocked(%v) = %v, want %v", tc.errno, got, tc.blocked)
+		}
+		if got := IsBlocked(tc.errno); got != tc.blocked {
+			t.Errorf("IsBlocked(bare %v) = %v, want %v", tc.errno, got, tc.blocked)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tcp
 
 type event struct {
-	Fd  int
-	Err error
+	Fd     int
+	Events uint32
+	Err    error
 }
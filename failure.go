@@ -0,0 +1,87 @@
+package tcp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// FailureKind is the category of a failed check.
+type FailureKind int
+
+const (
+	// FailureNone means the check succeeded.
+	FailureNone FailureKind = iota
+	// FailureRefused means the remote refused the connection.
+	FailureRefused
+	// FailureTimeout means no response was received in time.
+	FailureTimeout
+	// FailureUnreachable means the remote host or network is unreachable.
+	FailureUnreachable
+	// FailureBlocked means the connect was blocked by local policy, see ErrBlocked.
+	FailureBlocked
+	// FailureOther means any other failure.
+	FailureOther
+	// FailureLocal means the local network is not usable(e.g. the source interface is down)
+	// or the Checker itself failed(see ErrInternal), rather than the remote being unavailable.
+	FailureLocal
+)
+
+var failureKindNames = [...]string{
+	FailureNone:        "none",
+	FailureRefused:     "refused",
+	FailureTimeout:     "timeout",
+	FailureUnreachable: "unreachable",
+	FailureBlocked:     "blocked",
+	FailureOther:       "other",
+	FailureLocal:       "local",
+}
+
+func (k FailureKind) String() string {
+	if k < 0 || int(k) >= len(failureKindNames) {
+		return "unknown"
+	}
+	return failureKindNames[k]
+}
+
+// ClassifyFunc maps the error number of a failed connect to a FailureKind.
+type ClassifyFunc func(errno syscall.Errno) FailureKind
+
+// DefaultClassify is the ClassifyFunc used unless WithClassifier is set.
+func DefaultClassify(errno syscall.Errno) FailureKind {
+	switch errno {
+	case syscall.ECONNREFUSED:
+		return FailureRefused
+	case syscall.ETIMEDOUT:
+		return FailureTimeout
+	case syscall.EHOSTUNREACH, syscall.ENETUNREACH:
+		return FailureUnreachable
+	case syscall.EACCES, syscall.EPERM:
+		return FailureBlocked
+	case syscall.ENETDOWN, syscall.EADDRNOTAVAIL, syscall.EADDRINUSE:
+		return FailureLocal
+	}
+	return FailureOther
+}
+
+// Classify returns the FailureKind of err returned by a check.
+// Timeouts are always FailureTimeout, failures of the Checker itself are FailureLocal, errors with an error number are classified
+// by the ClassifyFunc set with WithClassifier, the rest are FailureOther.
+func (c *Checker) Classify(err error) FailureKind {
+	if err == nil {
+		return FailureNone
+	}
+	if t, ok := err.(interface{ Timeout() bool }); ok && t.Timeout() {
+		return FailureTimeout
+	}
+	if IsInternal(err) {
+		return FailureLocal
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if c.classify != nil {
+			return c.classify(errno)
+		}
+		return DefaultClassify(errno)
+	}
+	return FailureOther
+}
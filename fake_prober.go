@@ -0,0 +1,59 @@
+package tcp
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeProber is a Prober with scripted results, it's intended for testing code depending on Prober.
+// The zero value is ready for use, every check succeeds until results are scripted.
+type FakeProber struct {
+	l          sync.Mutex
+	results    map[string][]error
+	defaultErr error
+	calls      []string
+}
+
+// SetResults scripts the results of the following checks of addr in order,
+// the last one is repeated once the others are used up.
+func (f *FakeProber) SetResults(addr string, errs ...error) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.results == nil {
+		f.results = make(map[string][]error)
+	}
+	if len(errs) == 0 {
+		delete(f.results, addr)
+		return
+	}
+	f.results[addr] = append([]error(nil), errs...)
+}
+
+// SetDefault sets the result of the addresses without scripted results, nil by default.
+func (f *FakeProber) SetDefault(err error) {
+	f.l.Lock()
+	f.defaultErr = err
+	f.l.Unlock()
+}
+
+// CheckAddr returns the next scripted result of addr, timeout is ignored.
+func (f *FakeProber) CheckAddr(addr string, timeout time.Duration) error {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.calls = append(f.calls, addr)
+	errs, exists := f.results[addr]
+	if !exists {
+		return f.defaultErr
+	}
+	if len(errs) > 1 {
+		f.results[addr] = errs[1:]
+	}
+	return errs[0]
+}
+
+// Calls returns the addresses checked so far in order.
+func (f *FakeProber) Calls() []string {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return append([]string(nil), f.calls...)
+}
@@ -0,0 +1,55 @@
+package tcp
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// fdLimiter limits the number of fds opened at the same time.
+type fdLimiter struct {
+	slots chan struct{}
+	open  int64
+}
+
+func newFDLimiter(max int) *fdLimiter {
+	l := &fdLimiter{}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	return l
+}
+
+// acquire takes a slot for a new fd, ErrFDLimit is returned if no slot is freed before deadline.
+func (l *fdLimiter) acquire(ctx context.Context, deadline time.Time) error {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			select {
+			case l.slots <- struct{}{}:
+			case <-timer.C:
+				return ErrFDLimit
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	atomic.AddInt64(&l.open, 1)
+	return nil
+}
+
+// release frees the slot taken by acquire.
+func (l *fdLimiter) release() {
+	atomic.AddInt64(&l.open, -1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// count returns the number of fds currently opened.
+func (l *fdLimiter) count() int {
+	return int(atomic.LoadInt64(&l.open))
+}
@@ -0,0 +1,80 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The constants and struct in6_flowlabel_req of linux/in6.h, which are missing in x/sys/unix.
+const (
+	ipv6FlowlabelMgr  = 32
+	ipv6FlowinfoSend  = 33
+	ipv6FlActionGet   = 0
+	ipv6FlShareAny    = 255
+	ipv6FlFlagCreate  = 1
+	ipv6FlowlabelMask = 0x000fffff
+	ipv6FlowinfoMask  = 0x0fffffff
+)
+
+type flowlabelReq struct {
+	Dst     [16]byte
+	Label   [4]byte // big-endian
+	Action  uint8
+	Share   uint8
+	Flags   uint16
+	Expires uint16
+	Linger  uint16
+	_       uint32
+}
+
+// SetFlowInfo sets the sin6_flowinfo of the address every IPv6 target is connected to, i.e. the traffic class
+// and the flow label(the lower 20 bits), so that the flow label of the checks is under control, e.g. for
+// validating flow-label-based ECMP paths. IPV6_FLOWINFO_SEND is enabled on the probe sockets accordingly,
+// and a non-zero flow label is leased via IPV6_FLOWLABEL_MGR before connecting, shared by all the sockets.
+// An invalid or conflicting flow label fails the check with EINVAL or EPERM.
+// IPv4 targets are not affected, neither are the ones connected via their IPv4-mapped form.
+// NOTE: This must be called before checking.
+func (c *Checker) SetFlowInfo(flowinfo uint32) {
+	c.flowInfo = flowinfo & ipv6FlowinfoMask
+}
+
+// connectSockAddr connects fd to rAddr like connect, with the flowinfo set by SetFlowInfo if it applies.
+func (c *Checker) connectSockAddr(fd int, rAddr unix.Sockaddr) (success bool, err error) {
+	sa, ok := rAddr.(*unix.SockaddrInet6)
+	if !ok || c.flowInfo == 0 || net.IP(sa.Addr[:]).To4() != nil {
+		return connect(fd, rAddr)
+	}
+	if err = setFlowInfo(fd, sa.Addr, c.flowInfo); err != nil {
+		return false, err
+	}
+	raw := unix.RawSockaddrInet6{Family: unix.AF_INET6, Addr: sa.Addr, Scope_id: sa.ZoneId}
+	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&raw.Port))[:], uint16(sa.Port))
+	binary.BigEndian.PutUint32((*[4]byte)(unsafe.Pointer(&raw.Flowinfo))[:], c.flowInfo)
+	_, _, errno := unix.Syscall(unix.SYS_CONNECT, uintptr(fd), uintptr(unsafe.Pointer(&raw)), unix.SizeofSockaddrInet6)
+	if errno != 0 {
+		return connectResult(errno)
+	}
+	return connectResult(nil)
+}
+
+// setFlowInfo makes fd send the flowinfo of the address it connects to, the flow label of flowinfo
+// is leased for dst if it's not zero.
+func setFlowInfo(fd int, dst [16]byte, flowinfo uint32) error {
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, ipv6FlowinfoSend, 1); err != nil {
+		return err
+	}
+	if flowinfo&ipv6FlowlabelMask == 0 {
+		return nil
+	}
+	req := flowlabelReq{Dst: dst, Action: ipv6FlActionGet, Share: ipv6FlShareAny, Flags: ipv6FlFlagCreate}
+	binary.BigEndian.PutUint32(req.Label[:], flowinfo&ipv6FlowlabelMask)
+	_, _, errno := unix.Syscall6(unix.SYS_SETSOCKOPT, uintptr(fd), unix.IPPROTO_IPV6, ipv6FlowlabelMgr,
+		uintptr(unsafe.Pointer(&req)), unsafe.Sizeof(req), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -2,7 +2,4 @@ module github.com/Jarnpher553/tcp-shaker
 
 go 1.13
 
-require (
-	github.com/pkg/errors v0.9.1
-	golang.org/x/sys v0.0.0-20191110163157-d32e6e3b99c4
-)
+require golang.org/x/sys v0.0.0-20191110163157-d32e6e3b99c4
@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CheckHostPorts resolves host once and checks every port on every resolved IP in parallel.
+// The results are keyed by "ip:port", an error is returned if host can not be resolved.
+// NOTE: timeout includes domain resolving.
+func (c *Checker) CheckHostPorts(host string, ports []int, timeout time.Duration) (map[string]error, error) {
+	deadline := time.Now().Add(timeout)
+	ips, err := lookupHost(host, deadline)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips)*len(ports))
+	for _, ip := range ips {
+		for _, port := range ports {
+			addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(port)))
+		}
+	}
+	return c.CheckAddrs(addrs, time.Until(deadline)), nil
+}
+
+// FirstOpenPort checks every port on host in parallel and returns the first one passing the check,
+// the rest of the checks are canceled then. If none of them passed, *ErrAllFailed is returned
+// with the error of each "host:port" in the order of ports.
+func (c *Checker) FirstOpenPort(host string, ports []int, timeout time.Duration) (int, error) {
+	addrs := make([]string, len(ports))
+	for i, port := range ports {
+		addrs[i] = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	results, stoppedBy := c.CheckAddrsUntil(addrs, timeout, StopOnFirstSuccess)
+	allFailed := &ErrAllFailed{}
+	for i, addr := range addrs {
+		if addr == stoppedBy {
+			return ports[i], nil
+		}
+		if err, checked := results[addr]; checked {
+			allFailed.add(addr, err)
+		}
+	}
+	return 0, allFailed
+}
+
+// CheckHostDualStack resolves host once and checks port on its first IPv4 and IPv6 addresses in parallel,
+// unlike Happy Eyeballs both checks are completed so that the latencies of the two paths can be compared.
+// The result of a family without address has an *ErrNoAddressForFamily, an error is returned if host can not be resolved.
+// NOTE: timeout includes domain resolving.
+func (c *Checker) CheckHostDualStack(host string, port int, timeout time.Duration) (v4 CheckResult, v6 CheckResult, err error) {
+	deadline := time.Now().Add(timeout)
+	ips, err := lookupHost(host, deadline)
+	if err != nil {
+		return v4, v6, err
+	}
+	var ip4, ip6 string
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			// IPv6 addresses with zone are not parsed, which are never IPv4
+			if ip4 == "" {
+				ip4 = ip
+			}
+		} else if ip6 == "" {
+			ip6 = ip
+		}
+	}
+	var wg sync.WaitGroup
+	check := func(result *CheckResult, ip string, network string) {
+		defer wg.Done()
+		if ip == "" {
+			*result = CheckResult{
+				Addr:      net.JoinHostPort(host, strconv.Itoa(port)),
+				CheckedAt: time.Now(),
+				Err:       &ErrNoAddressForFamily{Host: host, Network: network},
+			}
+			result.Kind = c.Classify(result.Err)
+			return
+		}
+		*result = *c.checkAddr(context.Background(), net.JoinHostPort(ip, strconv.Itoa(port)), time.Until(deadline), c.zeroLinger)
+	}
+	wg.Add(2)
+	go check(&v4, ip4, "tcp4")
+	go check(&v6, ip6, "tcp6")
+	wg.Wait()
+	return v4, v6, nil
+}
+
+// lookupHost resolves host to IP addresses with zone if any.
+func lookupHost(host string, deadline time.Time) ([]string, error) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]string, 0, len(ipAddrs))
+	for _, ipAddr := range ipAddrs {
+		ips = append(ips, ipAddr.String())
+	}
+	return ips, nil
+}
@@ -0,0 +1,16 @@
+package tcp
+
+import "time"
+
+// TCPInfo is the part of TCP_INFO of a probe socket retrieved right after a successful connect.
+type TCPInfo struct {
+	// SndMSS is the effective MSS for sending(tcpi_snd_mss), limited by the MSS advertised by the server,
+	// which is lowered by MSS-clamping middleboxes on the path.
+	SndMSS uint32
+	// RcvMSS is the estimated MSS of the server(tcpi_rcv_mss).
+	RcvMSS uint32
+	// PMTU is the path MTU known by the kernel(tcpi_pmtu).
+	PMTU uint32
+	// RTT is the smoothed RTT estimated by the kernel(tcpi_rtt), which is the handshake RTT at this point.
+	RTT time.Duration
+}
@@ -0,0 +1,36 @@
+package tcp
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CheckAddrInfo performs a TCP check and retrieves TCP_INFO of the probe socket once connected,
+// the result of the check is reported via WithOnResult like the others.
+func (c *Checker) CheckAddrInfo(addr string, timeout time.Duration) (*TCPInfo, error) {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return nil, result.Err
+	}
+	defer c.inflight.Done()
+
+	var info *TCPInfo
+	result.Err = c.doCheckAddr(context.Background(), result, timeout, c.zeroLinger, func(fd int) error {
+		tcpInfo, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+		if err != nil {
+			return os.NewSyscallError("getsockopt", err)
+		}
+		info = &TCPInfo{
+			SndMSS: tcpInfo.Snd_mss,
+			RcvMSS: tcpInfo.Rcv_mss,
+			PMTU:   tcpInfo.Pmtu,
+			RTT:    time.Duration(tcpInfo.Rtt) * time.Microsecond,
+		}
+		return nil
+	})
+	return info, result.Err
+}
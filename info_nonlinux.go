@@ -0,0 +1,10 @@
+// +build !linux
+
+package tcp
+
+import "time"
+
+// CheckAddrInfo is not supported on this platform.
+func (c *Checker) CheckAddrInfo(addr string, timeout time.Duration) (*TCPInfo, error) {
+	return nil, &ErrUnsupported{Option: "TCP_INFO"}
+}
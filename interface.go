@@ -0,0 +1,27 @@
+package tcp
+
+import (
+	"context"
+	"time"
+)
+
+// Prober is the minimal interface for checking an address, it's implemented by Checker and FakeProber.
+// Consumers only checking addresses may depend on it, so that FakeProber can be injected in tests.
+type Prober interface {
+	CheckAddr(addr string, timeout time.Duration) error
+}
+
+// HealthChecker is the interface implemented by Checker.
+// Consumers may depend on it instead of *Checker, so that it can be replaced in tests.
+type HealthChecker interface {
+	Prober
+	CheckAddrContext(ctx context.Context, addr string) error
+	Close() error
+	IsReady() bool
+	WaitReady() <-chan struct{}
+}
+
+var (
+	_ HealthChecker = (*Checker)(nil)
+	_ Prober        = (*FakeProber)(nil)
+)
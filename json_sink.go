@@ -0,0 +1,85 @@
+package tcp
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// jsonSinkBuffer is the number of lines buffered for a slow writer, lines beyond it are dropped.
+const jsonSinkBuffer = 1024
+
+// jsonLine is a check result encoded as a line of JSON.
+type jsonLine struct {
+	ID    string  `json:"id,omitempty"`
+	Addr  string  `json:"addr"`
+	RTTMs float64 `json:"rtt_ms"`
+	Err   *string `json:"err"`
+	TS    string  `json:"ts"`
+}
+
+// jsonSink writes results to a writer in a separate goroutine so that checks never block on it.
+type jsonSink struct {
+	mu    sync.Mutex
+	lines chan []byte
+}
+
+// setWriter replaces the writer, results are no longer written if w is nil.
+func (s *jsonSink) setWriter(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lines != nil {
+		close(s.lines)
+		s.lines = nil
+	}
+	if w != nil {
+		s.lines = make(chan []byte, jsonSinkBuffer)
+		go writeLines(w, s.lines)
+	}
+}
+
+// emit queues result for writing, false is returned if it's dropped since the buffer is full.
+func (s *jsonSink) emit(result *CheckResult) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lines == nil {
+		return true
+	}
+	line := jsonLine{
+		ID:    result.ID,
+		Addr:  result.Addr,
+		RTTMs: float64(result.Latency) / float64(time.Millisecond),
+		TS:    result.CheckedAt.Format(time.RFC3339Nano),
+	}
+	if result.Err != nil {
+		errStr := result.Err.Error()
+		line.Err = &errStr
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return true
+	}
+	select {
+	case s.lines <- append(b, '\n'):
+		return true
+	default:
+		return false
+	}
+}
+
+// writeLines writes every line to w until lines is closed, errors of w are ignored.
+func writeLines(w io.Writer, lines <-chan []byte) {
+	for line := range lines {
+		w.Write(line)
+	}
+}
+
+// SetJSONSink writes the result of every check to w as a line of JSON, e.g.
+// {"addr":"127.0.0.1:80","rtt_ms":0.05,"err":null,"ts":"2006-01-02T15:04:05Z"}
+// Lines are written one at a time by a separate goroutine, so a slow writer never blocks checks,
+// lines are dropped instead once the buffer is full, see Stats.DroppedLines.
+// Passing nil stops writing, so does Close.
+func (c *Checker) SetJSONSink(w io.Writer) {
+	c.sink.setWriter(w)
+}
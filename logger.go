@@ -0,0 +1,42 @@
+package tcp
+
+import "errors"
+
+// Logger is used by Checker to report problems that don't fail a check, *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+func (c *Checker) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// errTimeWait is the warning of sockets being closed gracefully.
+var errTimeWait = errors.New("linger is not zero, every check leaves its socket in TIME_WAIT for 60 seconds, " +
+	"rapid re-checks may exhaust local ports")
+
+// warnTimeWait warns once that checks are closed gracefully, i.e. without the guarantee of zero linger
+// that sockets are reset and never enter TIME_WAIT, so that their local ports are reusable right away.
+func (c *Checker) warnTimeWait() {
+	c.warnOnce("TIME_WAIT", errTimeWait)
+}
+
+// safeCall calls f which runs a function supplied by the user, a panic is recovered and logged
+// so that it can't break the check or the checking loop, e.g. leaking the socket of the check.
+func (c *Checker) safeCall(name string, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logf("tcp-shaker: %s panicked: %v", name, r)
+		}
+	}()
+	f()
+}
+
+// warnOnce logs err as a warning once per key.
+func (c *Checker) warnOnce(key string, err error) {
+	if _, warned := c.warned.LoadOrStore(key, struct{}{}); !warned {
+		c.logf("tcp-shaker: warning: %s", err)
+	}
+}
@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// recentChecks spaces the checks of the same address by the interval set by WithMinInterval,
+// the concurrent checks of an address are coalesced into one.
+type recentChecks struct {
+	l        sync.Mutex
+	interval time.Duration
+	results  *resultCache
+	pending  map[string]*pendingCheck
+}
+
+// pendingCheck is a check in flight, result and reusable are set before done is closed.
+type pendingCheck struct {
+	done     chan struct{}
+	result   CheckResult
+	reusable bool
+}
+
+func newRecentChecks(interval time.Duration, maxTargets int) *recentChecks {
+	return &recentChecks{
+		interval: interval,
+		results:  newResultCache(maxTargets),
+		pending:  make(map[string]*pendingCheck),
+	}
+}
+
+// isConnectOutcome reports whether err is the outcome of a finished connect, only which is reused by WithMinInterval.
+// The errors of the Checker itself(e.g. ErrNotReady, ErrClosed) and of the contexts of the callers are never reused.
+func isConnectOutcome(err error) bool {
+	if err == nil || err == ErrClosedAfterConnect || err == ErrBacklogFull {
+		return true
+	}
+	var connErr *ErrConnect
+	return errors.As(err, &connErr) || errors.Is(err, ErrTimeout)
+}
+
+// checkAddr checks addr, the result of the previous check is returned with Cached set instead
+// if it's within the interval set by WithMinInterval, or the check of addr in flight is joined.
+func (c *Checker) checkAddr(ctx context.Context, addr string, timeout time.Duration, zeroLinger bool) *CheckResult {
+	if c.recent == nil {
+		return c.connectAddr(ctx, addr, timeout, zeroLinger)
+	}
+	r := c.recent
+	for {
+		r.l.Lock()
+		if result, ok := r.results.get(addr); ok && time.Since(result.CheckedAt) < r.interval {
+			r.l.Unlock()
+			return cachedResult(ctx, result)
+		}
+		p, exists := r.pending[addr]
+		if !exists {
+			p = &pendingCheck{done: make(chan struct{})}
+			r.pending[addr] = p
+			r.l.Unlock()
+			return c.leadCheck(ctx, p, addr, timeout, zeroLinger)
+		}
+		r.l.Unlock()
+		select {
+		case <-p.done:
+			if p.reusable {
+				return cachedResult(ctx, p.result)
+			}
+			// the check in flight was aborted for its caller, e.g. its context was canceled, retry
+		case <-ctx.Done():
+			result := newCheckResult(addr)
+			result.ID = checkID(ctx)
+			result.Err = ctx.Err()
+			c.recordResult(result)
+			return result
+		}
+	}
+}
+
+// leadCheck performs the check of p, which is joined by the concurrent checks of addr.
+func (c *Checker) leadCheck(ctx context.Context, p *pendingCheck, addr string, timeout time.Duration, zeroLinger bool) *CheckResult {
+	var result *CheckResult
+	r := c.recent
+	defer func() {
+		// the joined checks retry if the check panicked
+		r.l.Lock()
+		if result != nil && isConnectOutcome(result.Err) {
+			p.result, p.reusable = *result, true
+			r.results.put(p.result)
+		}
+		delete(r.pending, addr)
+		r.l.Unlock()
+		close(p.done)
+	}()
+	result = c.connectAddr(ctx, addr, timeout, zeroLinger)
+	return result
+}
+
+// cachedResult returns a copy of result for the check carrying ctx.
+func cachedResult(ctx context.Context, result CheckResult) *CheckResult {
+	result.ID = checkID(ctx)
+	result.Cached = true
+	return &result
+}
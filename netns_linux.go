@@ -0,0 +1,66 @@
+package tcp
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// SetNetns makes probe sockets be created in the network namespace of given path
+// (e.g. /var/run/netns/blue or /proc/<pid>/ns/net), an empty path restores the default.
+// Only the creation of sockets happens in the namespace, which is done in a dedicated goroutine
+// locked to its OS thread, so that neither the caller nor the checking loop leaves the current namespace.
+// It requires CAP_SYS_ADMIN, errors entering the namespace are returned as *ErrNetns.
+// NOTE: This must be called before checking.
+func (c *Checker) SetNetns(path string) error {
+	var netns *os.File
+	if path != "" {
+		var err error
+		if netns, err = os.Open(path); err != nil {
+			return &ErrNetns{Path: path, Err: err}
+		}
+	}
+	if c.netns != nil {
+		c.netns.Close()
+	}
+	c.netns = netns
+	return nil
+}
+
+// createSocket creates a socket in the network namespace set by SetNetns.
+func (c *Checker) createSocket(family int, zeroLinger bool) (int, error) {
+	if c.netns == nil {
+		return createSocketZeroLinger(family, zeroLinger)
+	}
+	var fd int
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fd, err = createSocketInNetns(c.netns, family, zeroLinger)
+	}()
+	<-done
+	return fd, err
+}
+
+// createSocketInNetns creates a socket in the network namespace of netns by switching the current thread,
+// it must be called in a goroutine which exits right after.
+func createSocketInNetns(netns *os.File, family int, zeroLinger bool) (int, error) {
+	// The thread is never unlocked if it's not restored, so that it's terminated along with the goroutine.
+	runtime.LockOSThread()
+	origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		return 0, &ErrNetns{Path: netns.Name(), Err: err}
+	}
+	defer origin.Close()
+	if err = unix.Setns(int(netns.Fd()), unix.CLONE_NEWNET); err != nil {
+		return 0, &ErrNetns{Path: netns.Name(), Err: os.NewSyscallError("setns", err)}
+	}
+	fd, err := createSocketZeroLinger(family, zeroLinger)
+	if rErr := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); rErr == nil {
+		runtime.UnlockOSThread()
+	}
+	return fd, err
+}
@@ -0,0 +1,305 @@
+package tcp
+
+import (
+	"net"
+	"time"
+)
+
+// Option configures a Checker.
+type Option func(*config)
+
+// config contains the options shared by all Checker implementations.
+type config struct {
+	maxOpenFiles     int
+	ipv4Mapped       bool
+	maxTargets       int
+	logger           Logger
+	strictOptions    bool
+	onResult         func(CheckResult)
+	classify         ClassifyFunc
+	adaptiveFloor    time.Duration
+	adaptiveK        float64
+	routingMark      *uint32
+	lockPollThread   bool
+	postConnectGrace time.Duration
+	vrf              string
+	onConnectStart   func(addr string, at time.Time)
+	fallbackDialer   *net.Dialer
+	gracefulClose    bool
+	minInterval      time.Duration
+	ioTimeout        time.Duration
+	singleSyn        bool
+	recvTimeout      time.Duration
+	synProgress      bool
+	sourceCIDR       string
+	network          string
+	defaultTimeout   time.Duration
+	connectDelay     time.Duration
+	strictHandshake  bool
+}
+
+func newConfig(opts []Option) config {
+	var conf config
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return conf
+}
+
+// WithIPv4MappedIPv6 makes the Checker connect to IPv4 addresses via AF_INET6 sockets,
+// using their IPv4-mapped form(::ffff:a.b.c.d). By default they are connected via AF_INET sockets.
+// NOTE: An IPv4-mapped address(e.g. ::ffff:192.0.2.1) is indistinguishable from the plain IPv4 one
+// after parsing, so both forms are always treated the same way.
+// This option is ignored on non-Linux platforms.
+func WithIPv4MappedIPv6() Option {
+	return func(conf *config) {
+		conf.ipv4Mapped = true
+	}
+}
+
+// WithResultCache keeps the latest result of at most maxTargets addresses,
+// which can be retrieved by LastResult. The least recently checked address is evicted first.
+func WithResultCache(maxTargets int) Option {
+	return func(conf *config) {
+		conf.maxTargets = maxTargets
+	}
+}
+
+// WithLogger sets the Logger for reporting problems that don't fail a check, nothing is logged by default.
+func WithLogger(logger Logger) Option {
+	return func(conf *config) {
+		conf.logger = logger
+	}
+}
+
+// WithStrictOptions controls how a socket option that requires privilege(e.g. SetMark, BindToDevice)
+// is handled when the privilege is missing. If strict is true, the check fails with ErrPrivilege,
+// otherwise the option is skipped and a warning is logged once, which is the default.
+func WithStrictOptions(strict bool) Option {
+	return func(conf *config) {
+		conf.strictOptions = strict
+	}
+}
+
+// WithOnResult sets a function to be called with the result of every finished check,
+// including the ones failed before connecting.
+// NOTE: The function is called synchronously before the check returns, it should return quickly.
+// A panic of it is recovered and logged, so are the ones of the other callbacks.
+func WithOnResult(onResult func(CheckResult)) Option {
+	return func(conf *config) {
+		conf.onResult = onResult
+	}
+}
+
+// WithClassifier overrides how the error number of a failed connect is mapped to a FailureKind,
+// e.g. to treat EACCES(rejected by iptables) as FailureRefused. DefaultClassify is used by default.
+func WithClassifier(classify ClassifyFunc) Option {
+	return func(conf *config) {
+		conf.classify = classify
+	}
+}
+
+// WithAdaptiveTimeout tightens the timeout of a target according to its recent RTTs,
+// so that a degraded target fails faster while a naturally slow one is not failed.
+// Once a target has enough successful checks, its timeout becomes max(floor, k * p95 RTT)
+// unless the timeout given to the check is less. The timeout in effect is reported in CheckResult.
+func WithAdaptiveTimeout(floor time.Duration, k float64) Option {
+	return func(conf *config) {
+		conf.adaptiveFloor = floor
+		conf.adaptiveK = k
+	}
+}
+
+// WithRoutingMark sets SO_MARK of every probe socket to mark like SetMark, so that the checks
+// are routed by the table selected by an ip rule(e.g. `ip rule add fwmark 1 table 100`).
+// A warning is logged if no ip rule references the mark, which is checked once on creation.
+// This option is ignored on non-Linux platforms.
+func WithRoutingMark(mark uint32) Option {
+	return func(conf *config) {
+		conf.routingMark = &mark
+	}
+}
+
+// WithLockedPollThread makes CheckingLoop lock the OS thread it runs on for its lifetime,
+// which reduces the scheduling jitter of measured latencies when the host is busy.
+// The trade-off is that a thread is dedicated to the loop, which is never used by other goroutines.
+// This option is ignored on non-Linux platforms.
+func WithLockedPollThread() Option {
+	return func(conf *config) {
+		conf.lockPollThread = true
+	}
+}
+
+// WithPostConnectGrace keeps watching the connection for d after a successful connect,
+// the check fails if the server resets or closes it within d, which is the case for
+// servers accepting connections and then rejecting them after a quick sanity check.
+// The handshake is completed with an ACK so that the server is able to accept.
+// d is not included in the measured Latency.
+// This option is ignored on non-Linux platforms.
+func WithPostConnectGrace(d time.Duration) Option {
+	return func(conf *config) {
+		conf.postConnectGrace = d
+	}
+}
+
+// WithVRF binds every probe socket to the named VRF(l3mdev) master device via SO_BINDTODEVICE,
+// so that the checks are routed by the table of the VRF. A warning is logged if the device
+// is not a VRF master, which is checked once on creation via sysfs.
+// It requires CAP_NET_RAW, see WithStrictOptions for how lack of privilege is handled.
+// This option is ignored on non-Linux platforms.
+func WithVRF(name string) Option {
+	return func(conf *config) {
+		conf.vrf = name
+	}
+}
+
+// WithOnConnectStart sets a function to be called once the connect of a check is issued,
+// at is the time right before connecting, which is also the start of the measured Latency.
+// Along with WithOnResult, it gives the two points in time for attributing latency precisely.
+// NOTE: The function is called synchronously after connect, it should return quickly.
+func WithOnConnectStart(onConnectStart func(addr string, at time.Time)) Option {
+	return func(conf *config) {
+		conf.onConnectStart = onConnectStart
+	}
+}
+
+// WithFallbackDialer makes every check of the fake implementation on non-Linux platforms connect via a copy of d,
+// so that its settings(e.g. LocalAddr, Control) are honored. Its Timeout is capped by the one of the check.
+// This option is ignored on Linux.
+func WithFallbackDialer(d *net.Dialer) Option {
+	return func(conf *config) {
+		conf.fallbackDialer = d
+	}
+}
+
+// WithGracefulClose makes the checks close connections gracefully with a FIN instead of a RST,
+// like creating the Checker by NewCheckerZeroLinger(false), for targets logging RST as security events.
+// Closing never blocks, but the probe sockets are left in TIME_WAIT for 60 seconds, which may exhaust
+// the local ports when checking the same target frequently, see net.ipv4.tcp_tw_reuse.
+// CheckAddrZeroLinger is not affected.
+func WithGracefulClose() Option {
+	return func(conf *config) {
+		conf.gracefulClose = true
+	}
+}
+
+// WithMinInterval spaces the checks of the same address by at least d, a check issued within d
+// since the previous one started returns the cached result instead of connecting, with Cached set,
+// which protects fragile targets probed by many callers. The trade-off is that the result may be stale by up to d.
+// Concurrent checks of the same address share a single connect.
+// Only finished connects are reused: the results of checks aborted by the Checker(e.g. ErrNotReady, ErrClosed)
+// or by the contexts of callers are not cached.
+// It requires WithResultCache, only the addresses remembered by the cache are spaced.
+// Only CheckAddr and its variants returning the plain result are affected.
+func WithMinInterval(d time.Duration) Option {
+	return func(conf *config) {
+		conf.minInterval = d
+	}
+}
+
+// WithIOTimeout bounds each wait for the socket to become readable or writable in the checks sending
+// or reading data after connecting(e.g. CheckAddrProbe, CheckAddrBanner) by d, besides their own timeouts,
+// so that a peer stalling in the middle can't hold a check for its whole timeout. ErrTimeout is returned
+// if a wait exceeds d. The TLS handshake of CheckAddrTLS is bounded by d as a whole.
+// The connect phase is not affected. Only the TLS handshake is bounded on non-Linux platforms.
+func WithIOTimeout(d time.Duration) Option {
+	return func(conf *config) {
+		conf.ioTimeout = d
+	}
+}
+
+// WithSingleSyn makes every check send exactly one SYN, the check fails with ErrTimeout(TimeoutReason ErrNoResponse)
+// if it's not answered before the initial RTO(1s, RFC 6298) at which the kernel would retransmit it.
+// So the effective timeout of the handshake becomes min(timeout, ~1s), which suits one-shot reachability scans.
+// TCP_SYNCNT is set to 1 as well, so that the kernel retransmits at most once in case a check outlives it.
+// This option is ignored on non-Linux platforms.
+func WithSingleSyn() Option {
+	return func(conf *config) {
+		conf.singleSyn = true
+	}
+}
+
+// WithRecvTimeout bounds the read phase of the checks reading from the server
+// (e.g. CheckAddrBanner, CheckAddrProbeRead) by d, besides their own timeouts, the smaller one wins.
+// It's a defensive measure for always-on agents.
+// This option is ignored on non-Linux platforms.
+func WithRecvTimeout(d time.Duration) Option {
+	return func(conf *config) {
+		conf.recvTimeout = d
+	}
+}
+
+// WithSynProgressCheck makes a check fail early with ErrTimeout(TimeoutReason ErrNoResponse) if its handshake is clearly stuck,
+// i.e. the SYN was retransmitted twice without any answer, which is about 2 seconds after connecting
+// with the initial RTO of 1s, or 3 seconds if the kernel backs off exponentially(no tcp_syn_linear_timeouts),
+// rather than waiting for the full timeout of a black-holed target.
+// The progress is inspected via TCP_INFO every 100ms while connecting, which costs a getsockopt
+// and re-registering the socket to the poller per interval per pending check.
+// This option is ignored on non-Linux platforms.
+func WithSynProgressCheck() Option {
+	return func(conf *config) {
+		conf.synProgress = true
+	}
+}
+
+// WithSourceCIDR makes the checks connect from the usable host addresses of cidr(e.g. 10.0.0.0/28) in turn,
+// which multiplies the ephemeral ports available to large scans, since they are exhausted per source address.
+// The addresses need not be assigned locally thanks to IP_FREEBIND, but the replies must be routed back to the host.
+// Only the targets of the same IP version as cidr are affected, at most 65536 addresses of it are used.
+// A warning is logged if cidr is invalid, in which case the option is ignored. See SourceUsage for the sockets
+// bound to each address.
+// This option is ignored on non-Linux platforms.
+func WithSourceCIDR(cidr string) Option {
+	return func(conf *config) {
+		conf.sourceCIDR = cidr
+	}
+}
+
+// WithNetwork restricts the addresses the checks connect to to the ones of network, "tcp4" for IPv4 only
+// or "tcp6" for IPv6 only, by default any of them is used. A check whose host has no address of network
+// fails fast with *ErrNoAddressForFamily, which tells an empty answer apart from a failed lookup(*net.DNSError).
+// NOTE: IPv4 addresses connected via their IPv4-mapped form(see WithIPv4MappedIPv6) are still IPv4 ones.
+func WithNetwork(network string) Option {
+	return func(conf *config) {
+		conf.network = network
+	}
+}
+
+// WithDefaultTimeout sets the timeout of CheckAddrContext, which is otherwise limited by its ctx only.
+// The sooner of the timeout and the deadline of ctx applies, so ctx may still shorten a check.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(conf *config) {
+		conf.defaultTimeout = d
+	}
+}
+
+// WithConnectDelay makes every check wait for d between creating its socket and connecting,
+// e.g. to simulate slow clients or to spread the SYNs of a burst of checks. The check fails with ErrTimeout
+// right away if d would exceed its timeout. d is not included in the measured Latency, but in the timeout.
+// NOTE: The wait is done by the goroutine of the check, the checking loop is never blocked.
+func WithConnectDelay(d time.Duration) Option {
+	return func(conf *config) {
+		conf.connectDelay = d
+	}
+}
+
+// WithStrictHandshake makes a check confirm that the handshake is complete once the socket is reported writable,
+// i.e. SO_ERROR is 0 and getpeername succeeds, otherwise it keeps waiting until its timeout,
+// which guards against the socket being reported writable spuriously. The measured Latency ends at the confirmation.
+// It costs two syscalls per successful check.
+// This option is ignored on non-Linux platforms.
+func WithStrictHandshake() Option {
+	return func(conf *config) {
+		conf.strictHandshake = true
+	}
+}
+
+// WithMaxOpenFiles limits the number of sockets opened by the Checker at the same time,
+// regardless of RLIMIT_NOFILE. A check exceeding the limit waits for a free slot until
+// its timeout, ErrFDLimit is returned if there is none.
+// Zero or negative n means no limit, which is the default.
+func WithMaxOpenFiles(n int) Option {
+	return func(conf *config) {
+		conf.maxOpenFiles = n
+	}
+}
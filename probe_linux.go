@@ -0,0 +1,157 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CheckAddrProbe performs a TCP check, then sends payload and waits for the first byte of response.
+// Besides the connect latency, the time from the payload being sent to the first byte received
+// is measured as TTFB of the result. timeout covers the whole process.
+func (c *Checker) CheckAddrProbe(addr string, timeout time.Duration, payload []byte) *CheckResult {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return result
+	}
+	defer c.inflight.Done()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(timeout)
+	result.Err = c.doCheckAddr(ctx, result, timeout, c.zeroLinger, func(fd int) error {
+		if err := c.writeAll(ctx, fd, payload, deadline); err != nil {
+			return err
+		}
+		sentAt := time.Now()
+		var firstByte [1]byte
+		_, err := c.readAtLeast(ctx, fd, firstByte[:], 1, deadline)
+		if err == nil {
+			result.TTFB = time.Since(sentAt)
+		}
+		return err
+	})
+	return result
+}
+
+// CheckAddrProbeRead performs a TCP check, sends payload if it's not empty, then returns what the server
+// sends(e.g. SMTP/SSH banners) until maxRead bytes are received, the connection is closed by the server,
+// or timeout is reached, which covers the whole process. An error is returned only if nothing is received.
+// An error is returned right away if maxRead is negative.
+func (c *Checker) CheckAddrProbeRead(addr string, timeout time.Duration, payload []byte, maxRead int) ([]byte, error) {
+	if maxRead < 0 {
+		return nil, fmt.Errorf("negative maxRead %d", maxRead)
+	}
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return nil, result.Err
+	}
+	defer c.inflight.Done()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(timeout)
+	received := make([]byte, maxRead)
+	var n int
+	result.Err = c.doCheckAddr(ctx, result, timeout, c.zeroLinger, func(fd int) error {
+		if err := c.writeAll(ctx, fd, payload, deadline); err != nil {
+			return err
+		}
+		var err error
+		n, err = c.readAtLeast(ctx, fd, received, maxRead, deadline)
+		if n > 0 {
+			// stopping early is expected since the length of response is unknown
+			return nil
+		}
+		return err
+	})
+	return received[:n], result.Err
+}
+
+// backlogPollInterval is how often the send queue is inspected by CheckAddrBacklog.
+const backlogPollInterval = time.Millisecond
+
+// CheckAddrBacklog performs a TCP check, then sends a byte and waits for it to be acknowledged
+// within window to detect a saturated accept queue: the server completes the handshake with SYN-ACK,
+// but drops the ACK and the byte along with it, or resets the connection if tcp_abort_on_overflow is set,
+// when the connection doesn't fit in the accept queue. ErrBacklogFull is returned in both cases.
+// timeout covers the connect only. NOTE: The byte(0x00) is received by the server once accepted.
+func (c *Checker) CheckAddrBacklog(addr string, timeout, window time.Duration) error {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	if result.Err = c.beginCheck(); result.Err != nil {
+		return result.Err
+	}
+	defer c.inflight.Done()
+
+	ctx := context.Background()
+	result.Err = c.doCheckAddr(ctx, result, timeout, c.zeroLinger, func(fd int) error {
+		deadline := time.Now().Add(window)
+		if err := c.writeAll(ctx, fd, []byte{0}, deadline); err != nil {
+			return err
+		}
+		return c.waitAcked(ctx, fd, deadline)
+	})
+	return result.Err
+}
+
+// waitAcked waits for everything written to fd to be acknowledged until deadline.
+// There is no event for being acknowledged, so the send queue is inspected periodically.
+// ctx.Err() or ErrClosed is returned if ctx is done or the Checker is closed meanwhile.
+func (c *Checker) waitAcked(ctx context.Context, fd int, deadline time.Time) error {
+	ticker := time.NewTicker(backlogPollInterval)
+	defer ticker.Stop()
+	for {
+		unacked, err := unix.IoctlGetInt(fd, unix.SIOCOUTQ)
+		if err != nil {
+			return os.NewSyscallError("ioctl", err)
+		}
+		if unacked == 0 {
+			return nil
+		}
+		if errCode, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR); err == nil && errCode != 0 {
+			// reset by the server
+			return ErrBacklogFull
+		}
+		if time.Now().After(deadline) {
+			return ErrBacklogFull
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.closing:
+			return ErrClosed
+		}
+	}
+}
+
+// ioWaitTimeout returns how long a read or write may wait for fd until deadline, bounded by WithIOTimeout.
+func (c *Checker) ioWaitTimeout(deadline time.Time) time.Duration {
+	timeout := time.Until(deadline)
+	if c.ioTimeout > 0 && c.ioTimeout < timeout {
+		return c.ioTimeout
+	}
+	return timeout
+}
+
+// writeAll writes data to non-blocking fd, waiting for it to be writable until deadline if necessary.
+func (c *Checker) writeAll(ctx context.Context, fd int, data []byte, deadline time.Time) error {
+	for len(data) > 0 {
+		n, err := unix.Write(fd, data)
+		switch {
+		case err == unix.EAGAIN || err == unix.EINTR:
+			if err = c.waitEvent(ctx, fd, c.ioWaitTimeout(deadline), rearmWriteEvents); err != nil {
+				return err
+			}
+		case err != nil:
+			return os.NewSyscallError("write", err)
+		default:
+			data = data[n:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// +build !linux
+
+package tcp
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// CheckAddrProbe performs a TCP check, then sends payload and waits for the first byte of response.
+// Besides the connect latency, the time from the payload being sent to the first byte received
+// is measured as TTFB of the result. timeout covers the whole process.
+func (c *Checker) CheckAddrProbe(addr string, timeout time.Duration, payload []byte) *CheckResult {
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	deadline := time.Now().Add(timeout)
+	startedAt := time.Now()
+	dialer := c.newDialer(timeout)
+	conn, err := dialer.Dial("tcp", addr)
+	result.Latency = time.Since(startedAt)
+	if err != nil {
+		result.Err = normalizeDialError(err)
+		return result
+	}
+	defer conn.Close()
+	result.LocalAddr = conn.LocalAddr()
+	if c.zeroLinger {
+		// Simply ignore the error since this is a fake implementation.
+		conn.(*net.TCPConn).SetLinger(0)
+	}
+
+	conn.SetDeadline(deadline)
+	if _, err = conn.Write(payload); err != nil {
+		result.Err = normalizeDialError(err)
+		return result
+	}
+	sentAt := time.Now()
+	var firstByte [1]byte
+	if _, err = conn.Read(firstByte[:]); err != nil {
+		result.Err = normalizeDialError(err)
+		return result
+	}
+	result.TTFB = time.Since(sentAt)
+	return result
+}
+
+// CheckAddrBacklog is not supported on this platform.
+func (c *Checker) CheckAddrBacklog(addr string, timeout, window time.Duration) error {
+	return &ErrUnsupported{Option: "CheckAddrBacklog"}
+}
+
+// CheckAddrProbeRead performs a TCP check, sends payload if it's not empty, then returns what the server
+// sends(e.g. SMTP/SSH banners) until maxRead bytes are received, the connection is closed by the server,
+// or timeout is reached, which covers the whole process. An error is returned only if nothing is received.
+// An error is returned right away if maxRead is negative.
+func (c *Checker) CheckAddrProbeRead(addr string, timeout time.Duration, payload []byte, maxRead int) ([]byte, error) {
+	if maxRead < 0 {
+		return nil, fmt.Errorf("negative maxRead %d", maxRead)
+	}
+	result := newCheckResult(addr)
+	defer c.recordResult(result)
+	deadline := time.Now().Add(timeout)
+	startedAt := time.Now()
+	dialer := c.newDialer(timeout)
+	conn, err := dialer.Dial("tcp", addr)
+	result.Latency = time.Since(startedAt)
+	if err != nil {
+		result.Err = normalizeDialError(err)
+		return nil, result.Err
+	}
+	defer conn.Close()
+	result.LocalAddr = conn.LocalAddr()
+	if c.zeroLinger {
+		// Simply ignore the error since this is a fake implementation.
+		conn.(*net.TCPConn).SetLinger(0)
+	}
+
+	conn.SetDeadline(deadline)
+	if len(payload) > 0 {
+		if _, err = conn.Write(payload); err != nil {
+			result.Err = normalizeDialError(err)
+			return nil, result.Err
+		}
+	}
+	received := make([]byte, maxRead)
+	var n int
+	for n < maxRead {
+		var nr int
+		nr, err = conn.Read(received[n:])
+		n += nr
+		if err != nil {
+			break
+		}
+	}
+	if n == 0 && err != nil {
+		result.Err = normalizeDialError(err)
+	}
+	return received[:n], result.Err
+}
@@ -0,0 +1,35 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+)
+
+// errNoSuitableAddress is the message of *net.AddrError returned if a host has no address of the network.
+const errNoSuitableAddress = "no suitable address found"
+
+// resolveTCPAddr resolves addr like net.ResolveTCPAddr, the default network "tcp" is used if network is empty.
+func resolveTCPAddr(network, addr string) (*net.TCPAddr, error) {
+	if network == "" {
+		network = "tcp"
+	}
+	tAddr, err := net.ResolveTCPAddr(network, addr)
+	if err != nil {
+		return nil, familyError(err, network, addr)
+	}
+	return tAddr, nil
+}
+
+// familyError converts err returned by net package on finding no address of network("tcp4" or "tcp6")
+// for the host of addr to *ErrNoAddressForFamily, other errors are returned as is.
+func familyError(err error, network, addr string) error {
+	var addrErr *net.AddrError
+	if network != "tcp4" && network != "tcp6" || !errors.As(err, &addrErr) || addrErr.Err != errNoSuitableAddress {
+		return err
+	}
+	host, _, splitErr := net.SplitHostPort(addr)
+	if splitErr != nil {
+		return err
+	}
+	return &ErrNoAddressForFamily{Host: host, Network: network}
+}
@@ -0,0 +1,33 @@
+package tcp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithNetwork(t *testing.T) {
+	for _, tc := range []struct {
+		network  string
+		addr     string
+		noFamily bool
+	}{
+		{"tcp4", "127.0.0.1:1", false},
+		{"tcp4", "[::1]:1", true},
+		{"tcp6", "127.0.0.1:1", true},
+		{"tcp6", "[::1]:1", false},
+		{"", "127.0.0.1:1", false},
+		{"", "[::1]:1", false},
+	} {
+		c, stop := startChecker(t, WithNetwork(tc.network))
+		err := c.CheckAddr(tc.addr, time.Second)
+		stop()
+		if got := errors.Is(err, &ErrNoAddressForFamily{}); got != tc.noFamily {
+			t.Errorf("checking %s over %q returned %v, want ErrNoAddressForFamily: %v", tc.addr, tc.network, err, tc.noFamily)
+			continue
+		}
+		if tc.noFamily && !errors.Is(err, &ErrNoAddressForFamily{Network: tc.network}) {
+			t.Errorf("checking %s over %q returned %v, want the network in the error", tc.addr, tc.network, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// CheckResult contains the details of a single check.
+type CheckResult struct {
+	// Addr is the address being checked.
+	Addr string
+	// ID is the correlation ID given to CheckAddrWithID, empty for the other checks.
+	ID string
+	// CheckedAt is the time when the check started.
+	CheckedAt time.Time
+	// LocalAddr is the local address used by the check, nil if unknown.
+	LocalAddr net.Addr
+	// RemoteAddr is the address resolved from Addr and connected to, nil if Addr can not be resolved.
+	RemoteAddr net.Addr
+	// Network is the network connected over, "tcp4" or "tcp6", which is the family of the probe socket,
+	// so it's "tcp6" for an IPv4 address connected via its IPv4-mapped form, see WithIPv4MappedIPv6.
+	// It's empty if Addr can not be resolved.
+	Network string
+	// Timeout is the timeout in effect, which may be tightened by WithAdaptiveTimeout.
+	Timeout time.Duration
+	// Latency is the time taken by the handshake, domain resolving is excluded.
+	Latency time.Duration
+	// TTFB is the time from the payload being sent to the first byte received,
+	// only available for CheckAddrProbe.
+	TTFB time.Duration
+	// Synchronous reports whether the connect succeeded immediately,
+	// without being resolved by the poller. It's always false on non-Linux platforms.
+	Synchronous bool
+	// Err is the error of the check, nil means the check succeeded.
+	Err error
+	// TimeoutReason is the subcategory of ErrTimeout if the connect timed out, which is ErrNoResponse
+	// or ErrNetworkTimeout, while Err is ErrTimeout itself. It's always nil on non-Linux platforms.
+	TimeoutReason error
+	// Kind is the category of Err, see Checker.Classify.
+	Kind FailureKind
+	// Cached reports whether this is the result of a previous check reused due to WithMinInterval.
+	Cached bool
+}
+
+func newCheckResult(addr string) *CheckResult {
+	return &CheckResult{Addr: addr, CheckedAt: time.Now()}
+}
+
+// OK reports whether the check succeeded.
+func (r *CheckResult) OK() bool {
+	return r.Err == nil
+}
+
+// IsTimeout reports whether the check failed due to timeout.
+func (r *CheckResult) IsTimeout() bool {
+	t, ok := r.Err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
+// Errno returns the underlying error number of a failed check, 0 if there is none.
+func (r *CheckResult) Errno() syscall.Errno {
+	var errno syscall.Errno
+	if errors.As(r.Err, &errno) {
+		return errno
+	}
+	return 0
+}
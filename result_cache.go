@@ -0,0 +1,48 @@
+package tcp
+
+import (
+	"container/list"
+	"sync"
+)
+
+// resultCache keeps the latest result of a bounded number of targets, the least recently
+// checked target is evicted when the cache is full.
+type resultCache struct {
+	l          sync.Mutex
+	maxTargets int
+	lru        *list.List
+	entries    map[string]*list.Element
+}
+
+func newResultCache(maxTargets int) *resultCache {
+	return &resultCache{
+		maxTargets: maxTargets,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (r *resultCache) put(result CheckResult) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if e, exists := r.entries[result.Addr]; exists {
+		e.Value = result
+		r.lru.MoveToFront(e)
+		return
+	}
+	r.entries[result.Addr] = r.lru.PushFront(result)
+	if r.lru.Len() > r.maxTargets {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(CheckResult).Addr)
+	}
+}
+
+func (r *resultCache) get(addr string) (CheckResult, bool) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if e, exists := r.entries[addr]; exists {
+		return e.Value.(CheckResult), true
+	}
+	return CheckResult{}, false
+}
@@ -1,7 +1,13 @@
 package tcp
 
 type resultPipes interface {
+	// popResultPipe removes the pipe of fd atomically, the caller must send exactly one result to it.
 	popResultPipe(int) (chan error, bool)
-	deregisterResultPipe(int)
-	registerResultPipe(int, chan error)
+	// deregisterResultPipe returns false if the pipe of fd was already popped.
+	deregisterResultPipe(int) bool
+	// registerResultPipe returns false if the fd was already registered, in which case nothing is changed.
+	registerResultPipe(int, chan error) bool
+	releaseResultPipes(error)
+	// trim releases the memory grown by a burst of checks if no pipe is registered.
+	trim()
 }
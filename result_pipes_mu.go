@@ -21,15 +21,39 @@ func (r *resultPipesMU) popResultPipe(fd int) (chan error, bool) {
 	return p, exists
 }
 
-func (r *resultPipesMU) deregisterResultPipe(fd int) {
+func (r *resultPipesMU) deregisterResultPipe(fd int) bool {
 	r.l.Lock()
+	_, exists := r.fdResultPipes[fd]
 	delete(r.fdResultPipes, fd)
 	r.l.Unlock()
+	return exists
 }
 
-func (r *resultPipesMU) registerResultPipe(fd int, pipe chan error) {
+func (r *resultPipesMU) releaseResultPipes(err error) {
+	r.l.Lock()
+	for fd, pipe := range r.fdResultPipes {
+		delete(r.fdResultPipes, fd)
+		pipe <- err
+	}
+	r.l.Unlock()
+}
+
+func (r *resultPipesMU) trim() {
+	r.l.Lock()
+	if len(r.fdResultPipes) == 0 {
+		// a map never shrinks, replace it
+		r.fdResultPipes = make(map[int]chan error)
+	}
+	r.l.Unlock()
+}
+
+func (r *resultPipesMU) registerResultPipe(fd int, pipe chan error) bool {
 	// NOTE: the pipe should have been put back if c.fdResultPipes[fd] exists.
 	r.l.Lock()
+	defer r.l.Unlock()
+	if _, exists := r.fdResultPipes[fd]; exists {
+		return false
+	}
 	r.fdResultPipes[fd] = pipe
-	r.l.Unlock()
+	return true
 }
@@ -0,0 +1,150 @@
+package tcp
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// sizeofFibRuleHdr is the size of struct fib_rule_hdr preceding the attributes of a rule.
+	sizeofFibRuleHdr = 12
+	// fibRuleHdrTable is the offset of table in struct fib_rule_hdr.
+	fibRuleHdrTable = 4
+	// fibRuleHdrAction is the offset of action in struct fib_rule_hdr.
+	fibRuleHdrAction = 7
+	// frActToTbl is FR_ACT_TO_TBL from linux/fib_rules.h, the action of looking up a table.
+	frActToTbl = 1
+	// fraFwmark is FRA_FWMARK from linux/fib_rules.h.
+	fraFwmark = 10
+	// fraTable is FRA_TABLE from linux/fib_rules.h.
+	fraTable = 15
+	// fraFwmask is FRA_FWMASK from linux/fib_rules.h.
+	fraFwmask = 16
+)
+
+// checkRoutingMark warns if no ip rule selects packets with given mark,
+// in which case the checks are routed by the main table as if no mark is set.
+func (c *Checker) checkRoutingMark(mark uint32) {
+	found, err := hasRuleForMark(mark)
+	if err != nil {
+		c.warnOnce("routing mark", fmt.Errorf("unable to verify ip rules of fwmark %#x: %s", mark, err))
+		return
+	}
+	if !found {
+		c.warnOnce("routing mark", fmt.Errorf("no ip rule references fwmark %#x, e.g. `ip rule add fwmark %#x table <table>`", mark, mark))
+	}
+}
+
+// SetRoutingTable makes the checks routed by the routing table of given id, by setting SO_MARK
+// of every probe socket like SetMark to the fwmark of the first ip rule selecting the table,
+// e.g. the one added by `ip rule add fwmark 100 table 100`. An error is returned if there is no such rule,
+// since a table can not be selected by a socket directly.
+// It requires CAP_NET_ADMIN, see WithStrictOptions for how lack of privilege is handled.
+// NOTE: This must be called before checking.
+func (c *Checker) SetRoutingTable(id int) error {
+	mark, found, err := markForTable(uint32(id))
+	if err != nil {
+		return fmt.Errorf("unable to list ip rules: %s", err)
+	}
+	if !found {
+		return fmt.Errorf("no ip rule selects table %d by fwmark, e.g. `ip rule add fwmark %d table %d`", id, id, id)
+	}
+	c.mark = mark
+	return nil
+}
+
+// fibRule is the part of an ip rule concerning fwmark.
+type fibRule struct {
+	action    uint8
+	table     uint32
+	fwmark    uint32
+	fwmask    uint32
+	hasFwmark bool
+}
+
+// hasRuleForMark reports whether there is an ip rule of any family matching given fwmark.
+func hasRuleForMark(mark uint32) (bool, error) {
+	rules, err := listRules()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range rules {
+		if r.hasFwmark && mark&r.fwmask == r.fwmark&r.fwmask {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// markForTable returns the fwmark of the first ip rule looking up given table by fwmark.
+func markForTable(table uint32) (mark uint32, found bool, err error) {
+	rules, err := listRules()
+	if err != nil {
+		return 0, false, err
+	}
+	for _, r := range rules {
+		if r.hasFwmark && r.action == frActToTbl && r.table == table {
+			return r.fwmark & r.fwmask, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// listRules returns the ip rules of all families in the order of priority.
+func listRules() ([]fibRule, error) {
+	tab, err := syscall.NetlinkRIB(syscall.RTM_GETRULE, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, os.NewSyscallError("netlinkrib", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(tab)
+	if err != nil {
+		return nil, os.NewSyscallError("parsenetlinkmessage", err)
+	}
+	var rules []fibRule
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWRULE || len(m.Data) < sizeofFibRuleHdr {
+			continue
+		}
+		rules = append(rules, parseRule(m.Data))
+	}
+	return rules, nil
+}
+
+// parseRule parses a rule consisting of struct fib_rule_hdr and its attributes.
+func parseRule(data []byte) fibRule {
+	r := fibRule{
+		action: data[fibRuleHdrAction],
+		table:  uint32(data[fibRuleHdrTable]),
+		fwmask: math.MaxUint32,
+	}
+	attrs := data[sizeofFibRuleHdr:]
+	for len(attrs) >= syscall.SizeofRtAttr {
+		attr := (*syscall.RtAttr)(unsafe.Pointer(&attrs[0]))
+		attrLen := int(attr.Len)
+		if attrLen < syscall.SizeofRtAttr || attrLen > len(attrs) {
+			break
+		}
+		if attrLen == syscall.SizeofRtAttr+4 {
+			value := *(*uint32)(unsafe.Pointer(&attrs[syscall.SizeofRtAttr]))
+			switch attr.Type {
+			case fraFwmark:
+				r.fwmark, r.hasFwmark = value, true
+			case fraFwmask:
+				r.fwmask = value
+			case fraTable:
+				// the table id in the header is truncated to 8 bits
+				r.table = value
+			}
+		}
+		// attributes are aligned to 4 bytes
+		alignedLen := (attrLen + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if alignedLen > len(attrs) {
+			break
+		}
+		attrs = attrs[alignedLen:]
+	}
+	return r
+}
@@ -70,37 +70,121 @@ func createPoller() (fd int, err error) {
 	return fd, err
 }
 
+// isPollerClosedError reports whether err is caused by polling on a closed poller.
+func isPollerClosedError(err error) bool {
+	if sErr, ok := err.(*os.SyscallError); ok {
+		err = sErr.Err
+	}
+	return err == unix.EBADF || err == unix.EINVAL
+}
+
+const (
+	connectEvents = unix.EPOLLOUT | unix.EPOLLIN | unix.EPOLLET
+	readEvents    = unix.EPOLLIN | unix.EPOLLRDHUP | unix.EPOLLET
+	writeEvents   = unix.EPOLLOUT | unix.EPOLLET
+	// EPOLLERR and EPOLLHUP are always reported
+	hangupEvents = unix.EPOLLRDHUP | unix.EPOLLET
+)
+
 // registerEvents registers given fd with read and write events.
+// If fd is already registered, it's modified with the same events instead.
 func registerEvents(pollerFd int, fd int) error {
+	op := unix.EPOLL_CTL_ADD
+	err := epollCtl(pollerFd, op, fd, connectEvents)
+	if err == unix.EEXIST {
+		op = unix.EPOLL_CTL_MOD
+		err = epollCtl(pollerFd, op, fd, connectEvents)
+	}
+	return wrapEpollCtlError(err, pollerFd, op, fd)
+}
+
+// unregisterEvents removes given fd from the poller.
+func unregisterEvents(pollerFd int, fd int) error {
+	err := unix.EpollCtl(pollerFd, unix.EPOLL_CTL_DEL, fd, nil)
+	return wrapEpollCtlError(err, pollerFd, unix.EPOLL_CTL_DEL, fd)
+}
+
+// rearmReadEvents re-arms given fd for read events only, so that it's not reported merely for being writable.
+func rearmReadEvents(pollerFd int, fd int) error {
+	return rearmEvents(pollerFd, fd, readEvents)
+}
+
+// rearmWriteEvents re-arms given fd for write events only.
+func rearmWriteEvents(pollerFd int, fd int) error {
+	return rearmEvents(pollerFd, fd, writeEvents)
+}
+
+// rearmHangupEvents re-arms given fd for the events of the connection being reset or closed only.
+func rearmHangupEvents(pollerFd int, fd int) error {
+	return rearmEvents(pollerFd, fd, hangupEvents)
+}
+
+// rearmEvents re-arms given fd with events so that its current readiness is reported again,
+// fd is registered if it was not yet.
+func rearmEvents(pollerFd int, fd int, events uint32) error {
+	op := unix.EPOLL_CTL_MOD
+	err := epollCtl(pollerFd, op, fd, events)
+	if err == unix.ENOENT {
+		op = unix.EPOLL_CTL_ADD
+		err = epollCtl(pollerFd, op, fd, events)
+	}
+	return wrapEpollCtlError(err, pollerFd, op, fd)
+}
+
+// epollCtl performs op on given fd with given events.
+func epollCtl(pollerFd int, op int, fd int, events uint32) error {
 	var event unix.EpollEvent
-	event.Events = unix.EPOLLOUT | unix.EPOLLIN | unix.EPOLLET
+	event.Events = events
 	event.Fd = int32(fd)
-	if err := unix.EpollCtl(pollerFd, unix.EPOLL_CTL_ADD, fd, &event); err != nil {
-		return os.NewSyscallError(fmt.Sprintf("epoll_ctl(%d, ADD, %d, ...)", pollerFd, fd), err)
+	return unix.EpollCtl(pollerFd, op, fd, &event)
+}
+
+var epollCtlOpNames = map[int]string{
+	unix.EPOLL_CTL_ADD: "ADD",
+	unix.EPOLL_CTL_MOD: "MOD",
+	unix.EPOLL_CTL_DEL: "DEL",
+}
+
+func wrapEpollCtlError(err error, pollerFd int, op int, fd int) error {
+	if err == nil {
+		return nil
 	}
-	return nil
+	return os.NewSyscallError(fmt.Sprintf("epoll_ctl(%d, %s, %d, ...)", pollerFd, epollCtlOpNames[op], fd), err)
 }
 
-func pollEvents(pollerFd int, timeout time.Duration) ([]event, error) {
-	var timeoutMS = int(timeout.Nanoseconds() / 1000000)
-	var epollEvents [maxEpollEvents]unix.EpollEvent
-	nEvents, err := unix.EpollWait(pollerFd, epollEvents[:], timeoutMS)
-	if err != nil {
-		if err == unix.EINTR {
+// pollEvents waits for at most len(epollEvents) events, epollEvents is used as a buffer.
+// An interrupted wait is counted in st and resumed with the remaining timeout.
+// epoll_pwait2(Linux 5.11+) is not used for a finer timeout: the checks time out by Go timers,
+// never by the poller, and the golang.org/x/sys/unix in use has no wrapper for it.
+func pollEvents(pollerFd int, epollEvents []unix.EpollEvent, timeout time.Duration, st *stats) ([]event, error) {
+	var deadline = time.Now().Add(timeout)
+	var nEvents int
+	for {
+		var err error
+		nEvents, err = unix.EpollWait(pollerFd, epollEvents, epollTimeoutMS(timeout))
+		if err == nil {
+			break
+		}
+		if err != unix.EINTR {
+			return nil, os.NewSyscallError("epoll_wait", err)
+		}
+		st.addInterrupt()
+		if timeout = time.Until(deadline); timeout <= 0 {
+			st.addWakeup(0)
 			return nil, nil
 		}
-		return nil, os.NewSyscallError("epoll_wait", err)
 	}
+	st.addWakeup(nEvents)
 
 	var events = make([]event, 0, nEvents)
 
 	for i := 0; i < nEvents; i++ {
 		var fd = int(epollEvents[i].Fd)
-		var evt = event{Fd: fd, Err: nil}
+		var evt = event{Fd: fd, Events: epollEvents[i].Events, Err: nil}
 
 		errCode, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
 		if err != nil {
-			evt.Err = os.NewSyscallError("getsockopt", err)
+			evt.Err = &ErrInternal{Op: "getsockopt SO_ERROR", Err: err}
 		}
 		if errCode != 0 {
 			evt.Err = newErrConnect(errCode)
@@ -110,14 +194,26 @@ func pollEvents(pollerFd int, timeout time.Duration) ([]event, error) {
 	return events, nil
 }
 
-// parseSockAddr resolves given addr to unix.Sockaddr
-func parseSockAddr(addr string) (sAddr unix.Sockaddr, family int, err error) {
-	tAddr, err := net.ResolveTCPAddr("tcp", addr)
+// epollTimeoutMS converts timeout to the milliseconds taken by epoll_wait, which is its resolution.
+// A positive timeout is rounded up, so that a sub-millisecond one doesn't become 0(non-blocking),
+// which would busy-spin the caller. A negative one is 0 since it would block indefinitely.
+func epollTimeoutMS(timeout time.Duration) int {
+	if timeout <= 0 {
+		return 0
+	}
+	return int((timeout + time.Millisecond - 1) / time.Millisecond)
+}
+
+// parseSockAddr resolves given addr of network("tcp", "tcp4" or "tcp6") to unix.Sockaddr
+// IPv4 addresses are resolved to AF_INET unless ipv4Mapped is true,
+// in which case they are resolved to their IPv4-mapped form of AF_INET6.
+func parseSockAddr(addr string, network string, ipv4Mapped bool) (sAddr unix.Sockaddr, family int, err error) {
+	tAddr, err := resolveTCPAddr(network, addr)
 	if err != nil {
 		return
 	}
 
-	if ip := tAddr.IP.To4(); ip != nil {
+	if ip := tAddr.IP.To4(); ip != nil && !ipv4Mapped {
 		var addr4 [net.IPv4len]byte
 		copy(addr4[:], ip)
 		sAddr = &unix.SockaddrInet4{Port: tAddr.Port, Addr: addr4}
@@ -140,9 +236,79 @@ func parseSockAddr(addr string) (sAddr unix.Sockaddr, family int, err error) {
 	return
 }
 
+// localAddr returns the local address of given fd, nil if unknown.
+func localAddr(fd int) net.Addr {
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		return nil
+	}
+	if tAddr := sockAddrToTCPAddr(sa); tAddr != nil {
+		return tAddr
+	}
+	return nil
+}
+
+// sockAddrToTCPAddr converts given unix.Sockaddr to *net.TCPAddr, nil is returned if not an IP address.
+func sockAddrToTCPAddr(sa unix.Sockaddr) *net.TCPAddr {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return &net.TCPAddr{IP: append(net.IP(nil), sa.Addr[:]...), Port: sa.Port}
+	case *unix.SockaddrInet6:
+		return &net.TCPAddr{IP: append(net.IP(nil), sa.Addr[:]...), Port: sa.Port}
+	}
+	return nil
+}
+
+// familyNetwork returns the network name of given address family, "tcp4" or "tcp6".
+func familyNetwork(family int) string {
+	if family == unix.AF_INET {
+		return "tcp4"
+	}
+	return "tcp6"
+}
+
+// listenerSockAddr returns the address to connect to for reaching the listener of given fd.
+// An unspecified listening address is replaced with the loopback address of the same family.
+func listenerSockAddr(listenerFd int) (sAddr unix.Sockaddr, family int, err error) {
+	accepting, err := unix.GetsockoptInt(listenerFd, unix.SOL_SOCKET, unix.SO_ACCEPTCONN)
+	if err != nil {
+		return nil, 0, os.NewSyscallError("getsockopt", err)
+	}
+	if accepting == 0 {
+		return nil, 0, fmt.Errorf("fd %d is not a listening socket", listenerFd)
+	}
+	sa, err := unix.Getsockname(listenerFd)
+	if err != nil {
+		return nil, 0, os.NewSyscallError("getsockname", err)
+	}
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		sAddr4 := &unix.SockaddrInet4{Port: sa.Port, Addr: sa.Addr}
+		if net.IP(sAddr4.Addr[:]).IsUnspecified() {
+			copy(sAddr4.Addr[:], net.IPv4(127, 0, 0, 1).To4())
+		}
+		return sAddr4, unix.AF_INET, nil
+	case *unix.SockaddrInet6:
+		sAddr6 := &unix.SockaddrInet6{Port: sa.Port, ZoneId: sa.ZoneId, Addr: sa.Addr}
+		if net.IP(sAddr6.Addr[:]).IsUnspecified() {
+			copy(sAddr6.Addr[:], net.IPv6loopback)
+		}
+		return sAddr6, unix.AF_INET6, nil
+	}
+	return nil, 0, &net.AddrError{
+		Err:  "unsupported address family",
+		Addr: fmt.Sprintf("fd %d", listenerFd),
+	}
+}
+
 // connect calls the connect syscall with error handled.
 func connect(fd int, addr unix.Sockaddr) (success bool, err error) {
-	switch serr := unix.Connect(fd, addr); serr {
+	return connectResult(unix.Connect(fd, addr))
+}
+
+// connectResult interprets the error returned by the connect syscall.
+func connectResult(serr error) (success bool, err error) {
+	switch serr {
 	case unix.EALREADY, unix.EINPROGRESS, unix.EINTR:
 		// Connection could not be made immediately but asynchronously.
 		success = false
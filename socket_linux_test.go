@@ -0,0 +1,143 @@
+package tcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestEpollTimeoutMS(t *testing.T) {
+	for _, tc := range []struct {
+		timeout time.Duration
+		ms      int
+	}{
+		{0, 0},
+		{time.Nanosecond, 1},
+		{500 * time.Microsecond, 1},
+		{999 * time.Microsecond, 1},
+		{time.Millisecond, 1},
+		{time.Millisecond + time.Nanosecond, 2},
+		{time.Second, 1000},
+		{-time.Nanosecond, 0},
+		{-time.Second, 0},
+	} {
+		if ms := epollTimeoutMS(tc.timeout); ms != tc.ms {
+			t.Errorf("epollTimeoutMS(%v) = %d, want %d", tc.timeout, ms, tc.ms)
+		}
+	}
+}
+
+func TestConnectLocalErrors(t *testing.T) {
+	c := NewChecker()
+	for _, errno := range []unix.Errno{unix.ENETDOWN, unix.EADDRNOTAVAIL} {
+		success, err := connectResult(errno)
+		if success || err != errno {
+			t.Fatalf("connectResult(%v) = %v, %v, want false, %v", errno, success, err, errno)
+		}
+		if kind := c.Classify(&ErrConnect{err}); kind != FailureLocal {
+			t.Errorf("Classify(%v) = %v, want %v", errno, kind, FailureLocal)
+		}
+	}
+	if kind := c.Classify(&ErrConnect{unix.ECONNREFUSED}); kind != FailureRefused {
+		t.Errorf("Classify(ECONNREFUSED) = %v, want %v", kind, FailureRefused)
+	}
+}
+
+func TestPollEventsSOErrorFailure(t *testing.T) {
+	pollerFd, err := createPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(pollerFd)
+	// getsockopt fails with ENOTSOCK on the writable end of a pipe
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+	if err := registerEvents(pollerFd, p[1]); err != nil {
+		t.Fatal(err)
+	}
+	evts, err := pollEvents(pollerFd, make([]unix.EpollEvent, 1), time.Second, &stats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 1 || evts[0].Fd != p[1] {
+		t.Fatalf("pollEvents returned %+v, want an event of fd %d", evts, p[1])
+	}
+	if !IsInternal(evts[0].Err) {
+		t.Fatalf("event error is %v, want *ErrInternal", evts[0].Err)
+	}
+	if kind := NewChecker().Classify(evts[0].Err); kind != FailureLocal {
+		t.Errorf("Classify(%v) = %v, want %v", evts[0].Err, kind, FailureLocal)
+	}
+}
+
+func TestParseSockAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr       string
+		ipv4Mapped bool
+		family     int
+		ip         net.IP
+	}{
+		{"192.0.2.1:80", false, unix.AF_INET, net.IPv4(192, 0, 2, 1).To4()},
+		{"192.0.2.1:80", true, unix.AF_INET6, net.ParseIP("::ffff:192.0.2.1")},
+		{"[::ffff:192.0.2.1]:80", false, unix.AF_INET, net.IPv4(192, 0, 2, 1).To4()},
+		{"[::ffff:192.0.2.1]:80", true, unix.AF_INET6, net.ParseIP("::ffff:192.0.2.1")},
+		{"[2001:db8::1]:80", false, unix.AF_INET6, net.ParseIP("2001:db8::1")},
+		{"[2001:db8::1]:80", true, unix.AF_INET6, net.ParseIP("2001:db8::1")},
+	} {
+		sAddr, family, err := parseSockAddr(tc.addr, "tcp", tc.ipv4Mapped)
+		if err != nil {
+			t.Errorf("parseSockAddr(%s, %v) returned %v", tc.addr, tc.ipv4Mapped, err)
+			continue
+		}
+		if family != tc.family {
+			t.Errorf("parseSockAddr(%s, %v) returned family %d, want %d", tc.addr, tc.ipv4Mapped, family, tc.family)
+		}
+		var ip net.IP
+		switch sa := sAddr.(type) {
+		case *unix.SockaddrInet4:
+			ip, family = sa.Addr[:], unix.AF_INET
+		case *unix.SockaddrInet6:
+			ip, family = sa.Addr[:], unix.AF_INET6
+		}
+		if family != tc.family || !bytes.Equal(ip, tc.ip) {
+			t.Errorf("parseSockAddr(%s, %v) returned %T with %v, want %v", tc.addr, tc.ipv4Mapped, sAddr, ip, tc.ip)
+		}
+	}
+}
+
+func TestRegisterEventsTwice(t *testing.T) {
+	pollerFd, err := createPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(pollerFd)
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+	for i := 0; i < 2; i++ {
+		if err := registerEvents(pollerFd, fds[0]); err != nil {
+			t.Fatalf("registering fd for the %d time: %v", i+1, err)
+		}
+	}
+	// the writable socket is reported once only
+	evts, err := pollEvents(pollerFd, make([]unix.EpollEvent, 4), 100*time.Millisecond, &stats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 1 || evts[0].Fd != fds[0] {
+		t.Fatalf("pollEvents returned %+v, want one event of fd %d", evts, fds[0])
+	}
+	if evts, err = pollEvents(pollerFd, make([]unix.EpollEvent, 4), 100*time.Millisecond, &stats{}); err != nil || len(evts) != 0 {
+		t.Fatalf("polling again returned %+v, %v, want no event", evts, err)
+	}
+}
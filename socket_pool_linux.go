@@ -0,0 +1,143 @@
+package tcp
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketPool keeps sockets created in advance by WarmupSockets, keyed by address family.
+type socketPool struct {
+	l       sync.Mutex
+	sockets map[int][]pooledSocket
+}
+
+type pooledSocket struct {
+	fd        int
+	expiresAt time.Time
+}
+
+func (p *socketPool) put(family int, fd int, expiresAt time.Time) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	if p.sockets == nil {
+		p.sockets = make(map[int][]pooledSocket)
+	}
+	p.sockets[family] = append(p.sockets[family], pooledSocket{fd: fd, expiresAt: expiresAt})
+}
+
+// take returns a socket of family which is not expired, the expired ones are closed along the way.
+func (p *socketPool) take(family int) (int, bool) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	now := time.Now()
+	sockets := p.sockets[family]
+	for len(sockets) > 0 {
+		s := sockets[0]
+		sockets = sockets[1:]
+		if now.Before(s.expiresAt) {
+			p.sockets[family] = sockets
+			return s.fd, true
+		}
+		unix.Close(s.fd)
+	}
+	delete(p.sockets, family)
+	return -1, false
+}
+
+// closeAll closes all the sockets in the pool.
+func (p *socketPool) closeAll() {
+	p.reclaim(time.Time{})
+}
+
+// reclaim closes the expired sockets, all of them if now is zero.
+func (p *socketPool) reclaim(now time.Time) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	for family, sockets := range p.sockets {
+		kept := sockets[:0]
+		for _, s := range sockets {
+			if now.IsZero() || !now.Before(s.expiresAt) {
+				unix.Close(s.fd)
+			} else {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.sockets, family)
+		} else {
+			p.sockets[family] = kept
+		}
+	}
+}
+
+// size returns the number of sockets in the pool.
+func (p *socketPool) size() int {
+	p.l.Lock()
+	defer p.l.Unlock()
+	var n int
+	for _, sockets := range p.sockets {
+		n += len(sockets)
+	}
+	return n
+}
+
+// Trim closes the sockets created by WarmupSockets and shrinks the internal bookkeeping grown by a burst of checks,
+// which releases the fds and memory held by the Checker during idle periods. It's safe to call at any time,
+// in-flight checks are not affected, and the following checks create sockets on demand until WarmupSockets is called again.
+func (c *Checker) Trim() {
+	c.sockets.closeAll()
+	c.resultPipes.trim()
+}
+
+// WarmupSockets creates n sockets with all options applied in advance for the following checks
+// of network("tcp4" or "tcp6"), so that a burst of checks doesn't pay for creating them.
+// The sockets not used within ttl are closed.
+// NOTE: The sockets are not counted by WithMaxOpenFiles until they are used by checks.
+// They're used by checks with the zeroLinger of the Checker only.
+func (c *Checker) WarmupSockets(n int, network string, ttl time.Duration) error {
+	var family int
+	switch network {
+	case "tcp4":
+		family = unix.AF_INET
+	case "tcp6":
+		family = unix.AF_INET6
+	default:
+		return unix.EAFNOSUPPORT
+	}
+	expiresAt := time.Now().Add(ttl)
+	for i := 0; i < n; i++ {
+		fd, err := c.newSocket(family, c.zeroLinger)
+		if err != nil {
+			return err
+		}
+		c.sockets.put(family, fd, expiresAt)
+	}
+	time.AfterFunc(ttl, func() { c.sockets.reclaim(time.Now()) })
+	return nil
+}
+
+// openSocket returns a socket with all options applied, which is taken from the pool if possible.
+func (c *Checker) openSocket(family int, zeroLinger bool) (int, error) {
+	if zeroLinger == c.zeroLinger {
+		if fd, ok := c.sockets.take(family); ok {
+			return fd, nil
+		}
+	}
+	return c.newSocket(family, zeroLinger)
+}
+
+// newSocket creates a socket with all options applied.
+func (c *Checker) newSocket(family int, zeroLinger bool) (int, error) {
+	fd, err := c.createSocket(family, zeroLinger)
+	if err != nil {
+		return -1, err
+	}
+	// Apply the options configured on the Checker
+	if err = c.setSocketOptions(fd); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
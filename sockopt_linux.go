@@ -0,0 +1,189 @@
+package tcp
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// SetNetClass always returns ErrUnsupported: the net_cls classid of a socket is taken from the cgroup
+// of the thread creating it, there is no socket option for it. Run the process in the net_cls cgroup
+// instead, or use SetPriority to have probe traffic classified by a qdisc.
+func (c *Checker) SetNetClass(classid uint32) error {
+	return &ErrUnsupported{Option: "net_cls classid"}
+}
+
+// SetPriority sets SO_PRIORITY of every probe socket to priority, which qdiscs such as HTB use
+// to pick a class directly when it matches one of their class handles(major<<16 | minor).
+// Priorities above 6 require CAP_NET_ADMIN, ErrPrivilege is returned if the process lacks it.
+// NOTE: This must be called before checking.
+func (c *Checker) SetPriority(priority uint32) error {
+	if err := probeSockOpt(func(fd int) error { return _setPriority(fd, priority) }); err != nil {
+		if isPrivilegeError(err) {
+			return &ErrPrivilege{Option: "SO_PRIORITY", Err: err}
+		}
+		return &ErrUnsupported{Option: "SO_PRIORITY", Err: err}
+	}
+	c.priority = priority
+	return nil
+}
+
+// SetCongestionControl makes probe sockets use the named TCP congestion control algorithm(e.g. bbr).
+// An error is returned if the algorithm is not available on the host,
+// see /proc/sys/net/ipv4/tcp_available_congestion_control.
+// NOTE: This must be called before checking.
+func (c *Checker) SetCongestionControl(name string) error {
+	if err := probeSockOpt(func(fd int) error { return _setCongestionControl(fd, name) }); err != nil && !isPrivilegeError(err) {
+		return &ErrUnsupported{Option: "TCP_CONGESTION " + name, Err: err}
+	}
+	c.congestionControl = name
+	return nil
+}
+
+// SetMark sets SO_MARK of every probe socket to mark, which can be matched by
+// iptables rules or used to select a routing table via ip rule.
+// It requires CAP_NET_ADMIN, see WithStrictOptions for how lack of privilege is handled.
+// NOTE: This must be called before checking.
+func (c *Checker) SetMark(mark uint32) error {
+	c.mark = mark
+	return nil
+}
+
+// BindToDevice binds every probe socket to the named network interface via SO_BINDTODEVICE.
+// It requires CAP_NET_RAW, see WithStrictOptions for how lack of privilege is handled.
+// NOTE: This must be called before checking.
+func (c *Checker) BindToDevice(name string) error {
+	if _, err := net.InterfaceByName(name); err != nil {
+		return err
+	}
+	c.device = name
+	return nil
+}
+
+// SetNoDelay sets TCP_NODELAY of every probe socket, which is off by default.
+// It only matters to the checks writing to the server(e.g. CheckAddrProbe), where Nagle's algorithm
+// may hold back small writes and inflate the measured TTFB. The handshake Latency is not affected.
+// NOTE: This must be called before checking.
+func (c *Checker) SetNoDelay(noDelay bool) {
+	c.noDelay = noDelay
+}
+
+// SetReuseAddr sets SO_REUSEADDR of every probe socket, which is off by default.
+// It allows binding to a local port still in TIME_WAIT, so it only matters when probe sockets
+// are bound to a local address before connecting, where it helps avoid ErrAddrInUse.
+// NOTE: This must be called before checking.
+func (c *Checker) SetReuseAddr(reuse bool) {
+	c.reuseAddr = reuse
+}
+
+// SetReusePort sets SO_REUSEPORT(Linux 3.9+) of every probe socket, which is off by default.
+// It allows sockets of the same user, e.g. those of multiple probers on the host,
+// to be bound to the same local address and port, so it only matters when probe sockets
+// are bound to a local address before connecting.
+// NOTE: This must be called before checking.
+func (c *Checker) SetReusePort(reuse bool) {
+	c.reusePort = reuse
+}
+
+// setSocketOptions applies the options configured on the Checker to fd.
+func (c *Checker) setSocketOptions(fd int) error {
+	if c.priority != 0 {
+		if err := c.setPrivilegedSockOpt("SO_PRIORITY", func() error { return _setPriority(fd, c.priority) }); err != nil {
+			return err
+		}
+	}
+	if c.congestionControl != "" {
+		if err := c.setPrivilegedSockOpt("TCP_CONGESTION", func() error { return _setCongestionControl(fd, c.congestionControl) }); err != nil {
+			return err
+		}
+	}
+	if c.mark != 0 {
+		if err := c.setPrivilegedSockOpt("SO_MARK", func() error { return _setMark(fd, c.mark) }); err != nil {
+			return err
+		}
+	}
+	if c.device != "" {
+		if err := c.setPrivilegedSockOpt("SO_BINDTODEVICE", func() error { return _bindToDevice(fd, c.device) }); err != nil {
+			return err
+		}
+	}
+	if c.noDelay {
+		if err := _setNoDelay(fd); err != nil {
+			return err
+		}
+	}
+	if c.reuseAddr {
+		if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+			return err
+		}
+	}
+	if c.reusePort {
+		if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+			return &ErrUnsupported{Option: "SO_REUSEPORT", Err: err}
+		}
+	}
+	if c.singleSyn {
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_SYNCNT, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPrivilegedSockOpt calls setOpt which sets a socket option that may require privilege.
+// If the privilege is missing, the check is aborted with ErrPrivilege when the options are strict,
+// otherwise the option is skipped with a warning logged.
+func (c *Checker) setPrivilegedSockOpt(option string, setOpt func() error) error {
+	err := setOpt()
+	if err == nil {
+		return nil
+	}
+	if !isPrivilegeError(err) {
+		return err
+	}
+	pErr := &ErrPrivilege{Option: option, Err: err}
+	if c.strictOptions {
+		return pErr
+	}
+	c.warnOnce(option, pErr)
+	return nil
+}
+
+func isPrivilegeError(err error) bool {
+	return err == unix.EPERM || err == unix.EACCES
+}
+
+// setPriority sets SO_PRIORITY of given fd to priority
+func _setPriority(fd int, priority uint32) error {
+	return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_PRIORITY, int(priority))
+}
+
+// setCongestionControl sets TCP_CONGESTION of given fd to name
+func _setCongestionControl(fd int, name string) error {
+	return unix.SetsockoptString(fd, unix.IPPROTO_TCP, unix.TCP_CONGESTION, name)
+}
+
+// setMark sets SO_MARK of given fd to mark
+func _setMark(fd int, mark uint32) error {
+	return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_MARK, int(mark))
+}
+
+// bindToDevice sets SO_BINDTODEVICE of given fd to device
+func _bindToDevice(fd int, device string) error {
+	return unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, device)
+}
+
+// setNoDelay sets TCP_NODELAY of given fd
+func _setNoDelay(fd int) error {
+	return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_NODELAY, 1)
+}
+
+// probeSockOpt reports whether setOpt succeeds on a throwaway socket.
+func probeSockOpt(setOpt func(fd int) error) error {
+	fd, err := _createSocket(unix.AF_INET)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return setOpt(fd)
+}
@@ -0,0 +1,173 @@
+package tcp
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxSourceAddrs limits the number of source addresses taken from the CIDR set by WithSourceCIDR.
+const maxSourceAddrs = 1 << 16
+
+// sourcePool rotates the source addresses of probe sockets across the usable hosts of a CIDR.
+type sourcePool struct {
+	base  *big.Int
+	ips   []net.IP
+	inUse []int64
+	next  uint64
+}
+
+// newSourcePool returns a pool of the usable host addresses of cidr, the network and broadcast
+// addresses of an IPv4 subnet are excluded unless it's a /31 or /32.
+func newSourcePool(cidr string) (*sourcePool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipNet.Mask.Size()
+	base := new(big.Int).SetBytes(ipNet.IP)
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	if bits == 32 && bits-ones >= 2 {
+		base.Add(base, big.NewInt(1))
+		size.Sub(size, big.NewInt(2))
+	}
+	n := maxSourceAddrs
+	if size.IsInt64() && size.Int64() < int64(n) {
+		n = int(size.Int64())
+	}
+	p := &sourcePool{base: base, ips: make([]net.IP, n), inUse: make([]int64, n)}
+	for i := range p.ips {
+		b := new(big.Int).Add(base, big.NewInt(int64(i))).Bytes()
+		ip := make(net.IP, len(ipNet.IP))
+		copy(ip[len(ip)-len(b):], b)
+		p.ips[i] = ip
+	}
+	return p, nil
+}
+
+// acquire returns the index of the next source address in turn and counts it as in use.
+func (p *sourcePool) acquire() int {
+	i := int((atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.ips)))
+	atomic.AddInt64(&p.inUse[i], 1)
+	return i
+}
+
+// release counts the source address at i as no longer in use.
+func (p *sourcePool) release(i int) {
+	atomic.AddInt64(&p.inUse[i], -1)
+}
+
+// hold counts ip as in use if it's one of the source addresses, the returned function releases it.
+func (p *sourcePool) hold(ip net.IP) (release func()) {
+	if ip4 := ip.To4(); ip4 != nil && p.ips[0].To4() != nil {
+		ip = ip4
+	}
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(ip), p.base)
+	if !offset.IsInt64() || offset.Int64() < 0 || offset.Int64() >= int64(len(p.ips)) || !p.ips[offset.Int64()].Equal(ip) {
+		return func() {}
+	}
+	i := int(offset.Int64())
+	atomic.AddInt64(&p.inUse[i], 1)
+	return func() { p.release(i) }
+}
+
+// usage returns the number of sockets bound to each source address.
+func (p *sourcePool) usage() map[string]int {
+	usage := make(map[string]int, len(p.ips))
+	for i, ip := range p.ips {
+		usage[ip.String()] = int(atomic.LoadInt64(&p.inUse[i]))
+	}
+	return usage
+}
+
+// SourceUsage returns the number of probe sockets currently bound to each source address
+// taken from the CIDR set by WithSourceCIDR, nil if there is none.
+func (c *Checker) SourceUsage() map[string]int {
+	if c.sources == nil {
+		return nil
+	}
+	return c.sources.usage()
+}
+
+// setSourceCIDR sets up the source addresses of probe sockets, a warning is logged if cidr is invalid.
+func (c *Checker) setSourceCIDR(cidr string) {
+	sources, err := newSourcePool(cidr)
+	if err != nil {
+		c.warnOnce("source CIDR", fmt.Errorf("source CIDR ignored: %s", err))
+		return
+	}
+	c.sources = sources
+}
+
+// sourceConn is a connection returned by Dial, its source address is counted as in use until it's closed.
+type sourceConn struct {
+	*net.TCPConn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *sourceConn) Close() error {
+	err := c.TCPConn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
+
+// holdSource makes the source address of conn counted as in use until conn is closed.
+func (c *Checker) holdSource(conn net.Conn) net.Conn {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if c.sources == nil || !ok {
+		return conn
+	}
+	lAddr, ok := tcpConn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return conn
+	}
+	return &sourceConn{TCPConn: tcpConn, release: c.sources.hold(lAddr.IP)}
+}
+
+// bindSource binds fd to the next source address of the same IP version as rAddr with IP_FREEBIND set,
+// so that the address needs not be assigned locally. The local port is still chosen on connect,
+// i.e. the ephemeral ports are shared per source address and destination.
+// The returned function releases the source address, which is a no-op if fd is not bound.
+func (c *Checker) bindSource(fd int, family int, rAddr unix.Sockaddr) (release func(), err error) {
+	release = func() {}
+	if c.sources == nil {
+		return release, nil
+	}
+	_, isIPv4 := rAddr.(*unix.SockaddrInet4)
+	if sa6, ok := rAddr.(*unix.SockaddrInet6); ok {
+		isIPv4 = net.IP(sa6.Addr[:]).To4() != nil
+	}
+	if isIPv4 != (c.sources.ips[0].To4() != nil) {
+		// connect from the default source address for the other IP version
+		return release, nil
+	}
+	i := c.sources.acquire()
+	ip := c.sources.ips[i]
+	var lAddr unix.Sockaddr
+	if family == unix.AF_INET {
+		sa := &unix.SockaddrInet4{}
+		copy(sa.Addr[:], ip.To4())
+		lAddr = sa
+	} else {
+		sa := &unix.SockaddrInet6{}
+		copy(sa.Addr[:], ip.To16())
+		lAddr = sa
+	}
+	if err = unix.SetsockoptInt(fd, unix.SOL_IP, unix.IP_FREEBIND, 1); err == nil {
+		// defer picking the local port to connect, so that it's only unique per 4-tuple
+		err = unix.SetsockoptInt(fd, unix.SOL_IP, unix.IP_BIND_ADDRESS_NO_PORT, 1)
+	}
+	if err == nil {
+		err = unix.Bind(fd, lAddr)
+	}
+	if err != nil {
+		c.sources.release(i)
+		return release, &ErrInternal{Op: "bind " + ip.String(), Err: err}
+	}
+	return func() { c.sources.release(i) }, nil
+}
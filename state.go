@@ -0,0 +1,32 @@
+package tcp
+
+// State is the lifecycle state of a Checker.
+type State int
+
+const (
+	// StateNew means CheckingLoop is not running, either not started yet or stopped by its ctx.
+	StateNew State = iota
+	// StateStarting means CheckingLoop is creating the poller.
+	StateStarting
+	// StateRunning means CheckingLoop is running, checks can be issued.
+	StateRunning
+	// StateShuttingDown means Close was called and it's waiting for in-flight checks to return.
+	StateShuttingDown
+	// StateClosed means Close returned, the Checker can no longer be used.
+	StateClosed
+)
+
+var stateNames = [...]string{
+	StateNew:          "new",
+	StateStarting:     "starting",
+	StateRunning:      "running",
+	StateShuttingDown: "shutting down",
+	StateClosed:       "closed",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "unknown"
+	}
+	return stateNames[s]
+}
@@ -0,0 +1,99 @@
+package tcp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats contains the counters of a Checker.
+type Stats struct {
+	// Interrupts is the number of times polling was interrupted by signals(EINTR),
+	// which are mostly caused by the preemption of Go runtime.
+	Interrupts uint64
+	// IdleWakeups is the number of times polling returned due to timeout without any event.
+	IdleWakeups uint64
+	// EventWakeups is the number of times polling returned with events.
+	EventWakeups uint64
+	// Events is the number of events returned by polling,
+	// Events/EventWakeups close to the limit set by SetEventBatchLimit means the loop is event-bound.
+	Events uint64
+	// DroppedLines is the number of results not written to the sink set by SetJSONSink
+	// since the writer was falling behind.
+	DroppedLines uint64
+}
+
+// stats is the internal counters, it's updated atomically.
+type stats struct {
+	interrupts   uint64
+	idleWakeups  uint64
+	eventWakeups uint64
+	events       uint64
+	droppedLines uint64
+	// lastTick is the time in UnixNano when the checking loop was last alive.
+	lastTick int64
+}
+
+func (s *stats) addInterrupt() {
+	atomic.AddUint64(&s.interrupts, 1)
+}
+
+// tick records that the checking loop is alive.
+func (s *stats) tick() {
+	atomic.StoreInt64(&s.lastTick, time.Now().UnixNano())
+}
+
+// lastTickTime returns the time of the last tick, zero if there is none.
+func (s *stats) lastTickTime() time.Time {
+	if t := atomic.LoadInt64(&s.lastTick); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// addWakeup counts a return of polling with n events.
+func (s *stats) addWakeup(n int) {
+	s.tick()
+	if n == 0 {
+		atomic.AddUint64(&s.idleWakeups, 1)
+		return
+	}
+	atomic.AddUint64(&s.eventWakeups, 1)
+	atomic.AddUint64(&s.events, uint64(n))
+}
+
+func (s *stats) addDroppedLine() {
+	atomic.AddUint64(&s.droppedLines, 1)
+}
+
+func (s *stats) snapshot() Stats {
+	return Stats{
+		Interrupts:   atomic.LoadUint64(&s.interrupts),
+		IdleWakeups:  atomic.LoadUint64(&s.idleWakeups),
+		EventWakeups: atomic.LoadUint64(&s.eventWakeups),
+		Events:       atomic.LoadUint64(&s.events),
+		DroppedLines: atomic.LoadUint64(&s.droppedLines),
+	}
+}
+
+// reset zeroes the counters and returns their values before.
+func (s *stats) reset() Stats {
+	return Stats{
+		Interrupts:   atomic.SwapUint64(&s.interrupts, 0),
+		IdleWakeups:  atomic.SwapUint64(&s.idleWakeups, 0),
+		EventWakeups: atomic.SwapUint64(&s.eventWakeups, 0),
+		Events:       atomic.SwapUint64(&s.events, 0),
+		DroppedLines: atomic.SwapUint64(&s.droppedLines, 0),
+	}
+}
+
+// Stats returns a snapshot of the counters of the Checker.
+func (c *Checker) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// StatsAndReset is like Stats but zeroes the counters as well, so that each call returns
+// the counts since the previous one. Every count is reported exactly once,
+// while the counters may be updated in between being swapped.
+func (c *Checker) StatsAndReset() Stats {
+	return c.stats.reset()
+}
@@ -0,0 +1,46 @@
+package tcptest
+
+import (
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// StartBlackholeServer starts a server whose SYNs are silently dropped, checks against it time out.
+// It's a listener never accepting with its accept queue filled up, no firewall rule is necessary.
+func StartBlackholeServer() (*Server, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	ln := os.NewFile(uintptr(fd), "blackhole")
+	if err = unix.Bind(fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		ln.Close()
+		return nil, os.NewSyscallError("bind", err)
+	}
+	// the accept queue is full once a connection is queued
+	if err = unix.Listen(fd, 0); err != nil {
+		ln.Close()
+		return nil, os.NewSyscallError("listen", err)
+	}
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		ln.Close()
+		return nil, os.NewSyscallError("getsockname", err)
+	}
+	s := &Server{
+		Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(sa.(*unix.SockaddrInet4).Port)),
+		closers: []io.Closer{ln},
+	}
+	filler, err := net.DialTimeout("tcp", s.Addr, time.Second)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.closers = append(s.closers, filler)
+	return s, nil
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package tcptest
+
+import "errors"
+
+// StartBlackholeServer is not supported on this platform since whether SYNs to a full accept queue
+// are dropped differs across platforms.
+func StartBlackholeServer() (*Server, error) {
+	return nil, errors.New("tcptest: blackhole server is not supported on this platform")
+}
@@ -0,0 +1,59 @@
+// Package tcptest provides targets for exercising the success, refused and timeout paths
+// of TCP checks deterministically, which is handy for tests and benchmarks.
+package tcptest
+
+import (
+	"io"
+	"net"
+)
+
+// Server is a target listening on the loopback.
+type Server struct {
+	// Addr is the address of the server, e.g. 127.0.0.1:34567.
+	Addr    string
+	closers []io.Closer
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	var err error
+	for _, c := range s.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// StartEchoServer starts a server which accepts every connection and echoes what it receives,
+// checks against it succeed.
+func StartEchoServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return &Server{Addr: ln.Addr().String(), closers: []io.Closer{ln}}, nil
+}
+
+// RefusedAddr returns a loopback address nothing is listening on, checks against it are refused.
+// The port was just released, so it's unlikely but possible to be taken by others afterwards.
+func RefusedAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := ln.Addr().String()
+	return addr, ln.Close()
+}
@@ -0,0 +1,54 @@
+package tcptest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEchoServer(t *testing.T) {
+	s, err := StartEchoServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := net.DialTimeout("tcp", s.Addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("read %q, %v, want the echo", buf, err)
+	}
+}
+
+func TestRefusedAddr(t *testing.T) {
+	addr, err := RefusedAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = net.DialTimeout("tcp", addr, time.Second)
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("dial returned %v, want ECONNREFUSED", err)
+	}
+}
+
+func TestBlackholeServer(t *testing.T) {
+	s, err := StartBlackholeServer()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer s.Close()
+	_, err = net.DialTimeout("tcp", s.Addr, 100*time.Millisecond)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("dial returned %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,66 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// TLSResult contains the details of a check performed by CheckAddrTLS.
+type TLSResult struct {
+	// ConnectRTT is the time taken by the TCP handshake.
+	ConnectRTT time.Duration
+	// HandshakeDuration is the time taken by the TLS handshake, including the failed one.
+	HandshakeDuration time.Duration
+	// Version is the negotiated TLS version(e.g. tls.VersionTLS13), zero if the handshake failed.
+	Version uint16
+	// CipherSuite is the negotiated cipher suite, zero if the handshake failed.
+	CipherSuite uint16
+}
+
+// CheckAddrTLS connects to addr like Dial and then performs a TLS handshake with config,
+// the time taken by each of them is reported separately, so that slowness can be attributed to L4 or L7.
+// ServerName is derived from addr if config is nil or its ServerName is empty.
+// timeout covers the whole process, the timing collected so far is returned on failure as well.
+func (c *Checker) CheckAddrTLS(addr string, timeout time.Duration, config *tls.Config) (*TLSResult, error) {
+	deadline := time.Now().Add(timeout)
+	conn, result := c.dial(addr, timeout)
+	tlsResult := &TLSResult{ConnectRTT: result.Latency}
+	if result.Err != nil {
+		return tlsResult, result.Err
+	}
+	defer func() {
+		if tcpConn, ok := conn.(interface{ SetLinger(sec int) error }); ok && c.zeroLinger {
+			tcpConn.SetLinger(0)
+		}
+		conn.Close()
+	}()
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			config.ServerName = host
+		}
+	}
+	if c.ioTimeout > 0 && time.Now().Add(c.ioTimeout).Before(deadline) {
+		deadline = time.Now().Add(c.ioTimeout)
+	}
+	conn.SetDeadline(deadline)
+	tlsConn := tls.Client(conn, config)
+	startedAt := time.Now()
+	err := tlsConn.Handshake()
+	tlsResult.HandshakeDuration = time.Since(startedAt)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return tlsResult, ErrReadTimeout
+		}
+		return tlsResult, err
+	}
+	state := tlsConn.ConnectionState()
+	tlsResult.Version = state.Version
+	tlsResult.CipherSuite = state.CipherSuite
+	return tlsResult, nil
+}
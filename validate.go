@@ -0,0 +1,13 @@
+package tcp
+
+// ValidateAddrs validates every given address with ValidateAddr,
+// only the invalid ones are included in the returned map, which is empty if all of them are valid.
+func ValidateAddrs(addrs []string) map[string]error {
+	errs := make(map[string]error)
+	for _, addr := range addrs {
+		if err := ValidateAddr(addr); err != nil {
+			errs[addr] = err
+		}
+	}
+	return errs
+}
@@ -0,0 +1,9 @@
+package tcp
+
+// ValidateAddr resolves given addr the same way as CheckAddr does without connecting,
+// an error is returned if it could not be resolved or its address family is not supported.
+// NOTE: Domain names are resolved, which may block.
+func ValidateAddr(addr string) error {
+	_, _, err := parseSockAddr(addr, "", false)
+	return err
+}
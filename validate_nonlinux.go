@@ -0,0 +1,13 @@
+// +build !linux
+
+package tcp
+
+import "net"
+
+// ValidateAddr resolves given addr the same way as CheckAddr does without connecting,
+// an error is returned if it could not be resolved.
+// NOTE: Domain names are resolved, which may block.
+func ValidateAddr(addr string) error {
+	_, err := net.ResolveTCPAddr("tcp", addr)
+	return err
+}
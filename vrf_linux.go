@@ -0,0 +1,36 @@
+package tcp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkVRF warns if the named device is not a VRF master, which is checked via its uevent in sysfs.
+func (c *Checker) checkVRF(name string) {
+	isVRF, err := isVRFDevice(name)
+	if err != nil {
+		c.warnOnce("vrf", fmt.Errorf("unable to verify VRF %s: %s", name, err))
+		return
+	}
+	if !isVRF {
+		c.warnOnce("vrf", fmt.Errorf("device %s is not a VRF master", name))
+	}
+}
+
+// isVRFDevice reports whether the named device is a VRF master.
+func isVRFDevice(name string) (bool, error) {
+	f, err := os.Open(filepath.Join("/sys/class/net", name, "uevent"))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if scanner.Text() == "DEVTYPE=vrf" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,31 @@
+package tcp
+
+import (
+	"context"
+	"net"
+)
+
+// Warmup prepares the Checker to reduce the latency of the first checks: a socket is created
+// with all options applied and closed immediately, and the host of every seed address
+// is resolved to prime the resolver cache of the system, if any.
+// It's safe to call Warmup multiple times, the work that is already done is skipped.
+// ErrNotReady is returned if CheckingLoop is not running, ErrClosed if the Checker is closed.
+func (c *Checker) Warmup(seeds ...string) error {
+	if err := c.warmupSocket(); err != nil {
+		return err
+	}
+	for _, seed := range seeds {
+		host, _, err := net.SplitHostPort(seed)
+		if err != nil {
+			host = seed
+		}
+		if _, warmed := c.warmedHosts.Load(host); warmed {
+			continue
+		}
+		if _, err = net.DefaultResolver.LookupHost(context.Background(), host); err != nil {
+			return err
+		}
+		c.warmedHosts.Store(host, struct{}{})
+	}
+	return nil
+}